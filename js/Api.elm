@@ -1,6 +1,8 @@
 module Api exposing
     ( Action(..)
+    , LobbyAction(..)
     , ServerAction(..)
+    , decodeLobbyMessage
     , decodeMessage
     , encodeToMessage
     )
@@ -18,7 +20,8 @@ type Action
     | Auction CardSeed
     | BidUpdated Int String
     | AuctionWon
-    | EffectActivated CardSeed String
+    | EffectActivated CardSeed String Int
+    | EffectExpired CardSeed Int
     | TradeCompleted (Material Int)
     | GameOver String
     | PlayerInfoUpdated (List PlayerInfo)
@@ -89,10 +92,16 @@ actionHelp a =
             D.succeed AuctionWon
 
         "effect_activated" ->
-            D.map2 EffectActivated
+            D.map3 EffectActivated
                 (D.field "card_id" D.int)
                 --[note] doesn't sync up with name change
                 (D.field "author" D.string)
+                (D.field "author_id" D.int)
+
+        "effect_expired" ->
+            D.map2 EffectExpired
+                (D.field "card_id" D.int)
+                (D.field "author_id" D.int)
 
         "trade_completed" ->
             D.map TradeCompleted <|
@@ -119,6 +128,50 @@ actionHelp a =
             D.fail ("Received unrecognized action from server: " ++ a)
 
 
+type LobbyAction
+    = LobbyGames (List GameSummary)
+    | LobbyGameUpdated GameSummary
+    | LobbyGameRemoved String
+
+
+decodeLobbyMessage : String -> Result String LobbyAction
+decodeLobbyMessage =
+    D.decodeString lobbyAction
+
+
+lobbyAction : D.Decoder LobbyAction
+lobbyAction =
+    D.field "action" D.string |> D.andThen lobbyActionHelp
+
+
+lobbyActionHelp : String -> D.Decoder LobbyAction
+lobbyActionHelp a =
+    case a of
+        "lobby_games" ->
+            D.map LobbyGames <|
+                D.field "games" (D.list gameSummary)
+
+        "lobby_game_updated" ->
+            D.map LobbyGameUpdated <|
+                D.field "game" gameSummary
+
+        "lobby_game_removed" ->
+            D.map LobbyGameRemoved <|
+                D.field "name" D.string
+
+        _ ->
+            D.fail ("Received unrecognized action from lobby: " ++ a)
+
+
+gameSummary : D.Decoder GameSummary
+gameSummary =
+    D.map4 GameSummary
+        (D.field "name" D.string)
+        (D.field "state" D.string)
+        (D.field "players" D.int)
+        (D.field "joinable" D.bool)
+
+
 fruit : D.Decoder Fruit
 fruit =
     D.string
@@ -1,5 +1,6 @@
 module BaseType exposing
     ( CardSeed
+    , GameSummary
     , PlayerInfo
     , StageType(..)
     , Uber(..)
@@ -25,6 +26,14 @@ type alias PlayerInfo =
     }
 
 
+type alias GameSummary =
+    { name : String
+    , state : String
+    , players : Int
+    , joinable : Bool
+    }
+
+
 type Uber number
     = Finite number
     | Infinite
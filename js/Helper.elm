@@ -1,4 +1,4 @@
-module Helper exposing (arrayRemove, bidIncrement, isErr, isOk, move, nextBid, tryApplyZoomCardEffect, tryApplyZoomCardEffectLocal)
+module Helper exposing (arrayRemove, bidIncrement, isErr, isOk, listRemoveFirst, move, nextBid, tryApplyZoomCardEffect, tryApplyZoomCardEffectLocal)
 
 import Api
 import Array exposing (Array)
@@ -107,6 +107,20 @@ arrayRemove i array =
             )
 
 
+listRemoveFirst : (a -> Bool) -> List a -> List a
+listRemoveFirst pred list =
+    case list of
+        [] ->
+            []
+
+        x :: xs ->
+            if pred x then
+                xs
+
+            else
+                x :: listRemoveFirst pred xs
+
+
 move :
     Fruit
     -> Int
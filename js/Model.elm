@@ -42,22 +42,22 @@ type AppModel
 type alias WelcomeModel =
     { gameNameInput : String
 
-    {- [tmp] [hack] right now this is needed
-       because we can only listen to ws
-       once we know the name, otherwise
-       the server will add us to a random
-       game
-
-       [note] we aren't using a bool, in case
-       input changes while waiting for server
-       response (though unlikely)
-    -}
+    -- the name which was asked for, once the player joins. It may be
+    -- empty, in which case the server picks a name for the game
     , submittedName : Maybe String
+
+    -- the games listed by the lobby, which can be joined by selecting them
+    , games : List GameSummary
     }
 
 
 type alias GameModel =
     { gameName : String
+
+    -- the name which the connection was opened with. The server may have
+    -- picked a different gameName, but reopening the connection with it
+    -- would join the game a second time
+    , connectionName : String
     , stage : Stage
     , name : String
     , gold : Int
@@ -113,6 +113,9 @@ type alias Factory =
 type alias Effect =
     { name : String
     , author : String
+
+    -- names can change or collide, so the author is told apart by their id
+    , authorId : Int
     , yieldRateModifier : Material Float
     , roundsLeft : Int
     }
@@ -134,12 +137,14 @@ initWelcomeModel : WelcomeModel
 initWelcomeModel =
     { gameNameInput = ""
     , submittedName = Nothing
+    , games = []
     }
 
 
-initGameModel : String -> GameModel
-initGameModel name =
+initGameModel : String -> String -> GameModel
+initGameModel connectionName name =
     { gameName = name
+    , connectionName = connectionName
     , stage = ReadyStage initReadyModel
     , name = "Anonymous"
     , gold = 25
@@ -20,6 +20,8 @@ type AppMsg
 type WelcomeMsg
     = JoinGameButton
     | GameNameInputChange String
+    | GameSelected String
+    | LobbyMsgReceived (Result String Api.LobbyAction)
 
 
 type GameMsg
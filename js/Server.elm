@@ -14,6 +14,11 @@ wsURL hostname gameName =
     "ws://" ++ hostname ++ "/join?game=" ++ gameName
 
 
+lobbyURL : String -> String
+lobbyURL hostname =
+    "ws://" ++ hostname ++ "/lobby"
+
+
 send :
     { m | hostname : String }
     -> String
@@ -30,3 +35,11 @@ listen :
     -> Sub Msg
 listen { hostname } gameName handler =
     WebSocket.listen (wsURL hostname gameName) (handler << Api.decodeMessage)
+
+
+listenLobby :
+    { m | hostname : String }
+    -> (Result String Api.LobbyAction -> Msg)
+    -> Sub Msg
+listenLobby { hostname } handler =
+    WebSocket.listen (lobbyURL hostname) (handler << Api.decodeLobbyMessage)
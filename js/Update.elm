@@ -31,11 +31,13 @@ subscriptions model =
                         ]
 
                     Nothing ->
-                        []
+                        [ Server.listenLobby model
+                            (AppMsg << WelcomeMsg << LobbyMsgReceived)
+                        ]
 
             Game m ->
                 [ Server.listen model
-                    m.gameName
+                    m.connectionName
                     (AppMsg << ServerMsgReceived)
                 , case Lens.get timer m.stage of
                     Just _ ->
@@ -126,12 +128,8 @@ updateApp ctx msg model =
 
 updateWelcome : Ctx WelcomeMsg -> WelcomeMsg -> Upd WelcomeModel
 updateWelcome { toServer } msg model =
-    case msg of
-        JoinGameButton ->
-            let
-                gameName =
-                    model.gameNameInput
-            in
+    let
+        joinGame gameName =
             { model | submittedName = Just gameName }
                 ! [ {- [question] sending Api.JoinGame even necessary?
                        or does the server add us to the game automatically
@@ -139,16 +137,54 @@ updateWelcome { toServer } msg model =
                     -}
                     toServer gameName (Api.JoinGame gameName)
                   ]
+    in
+    case msg of
+        JoinGameButton ->
+            joinGame model.gameNameInput
 
         GameNameInputChange str ->
             { model | gameNameInput = str } ! []
 
+        GameSelected gameName ->
+            joinGame gameName
+
+        LobbyMsgReceived (Ok action) ->
+            { model | games = updateLobby action model.games } ! []
+
+        LobbyMsgReceived (Err e) ->
+            model ! []
+
+
+updateLobby : Api.LobbyAction -> List GameSummary -> List GameSummary
+updateLobby action games =
+    case action of
+        Api.LobbyGames list ->
+            list
+
+        Api.LobbyGameUpdated summary ->
+            if List.any (\g -> g.name == summary.name) games then
+                List.map
+                    (\g ->
+                        if g.name == summary.name then
+                            summary
+
+                        else
+                            g
+                    )
+                    games
+
+            else
+                List.sortBy .name (summary :: games)
+
+        Api.LobbyGameRemoved name ->
+            List.filter (\g -> g.name /= name) games
+
 
 updateGame : Ctx GameMsg -> GameMsg -> Upd GameModel
 updateGame { toServer, toMsg } msg model =
     let
         toGameServer =
-            toServer model.gameName
+            toServer model.connectionName
     in
     case msg of
         ReadyMsg msg ->
@@ -363,7 +399,16 @@ handleAction : Api.Action -> Upd AppModel
 handleAction action model =
     case action of
         Api.Welcome name ->
-            Game (initGameModel name) ! []
+            let
+                connectionName =
+                    case model of
+                        WelcomeScreen m ->
+                            Maybe.withDefault name m.submittedName
+
+                        Game m ->
+                            m.connectionName
+            in
+            Game (initGameModel connectionName name) ! []
 
         Api.GameStateChanged stage ->
             tryUpdate game (changeStage stage) model
@@ -448,7 +493,7 @@ handleAction action model =
                 )
                 model
 
-        Api.EffectActivated cardId author ->
+        Api.EffectActivated cardId author authorId ->
             let
                 card =
                     Card.fromSeed cardId
@@ -456,6 +501,7 @@ handleAction action model =
                 effect =
                     { name = card.name
                     , author = author
+                    , authorId = authorId
                     , yieldRateModifier = card.yieldRateModifier
 
                     --[tmp] hard coded
@@ -468,6 +514,20 @@ handleAction action model =
                 )
                 model
 
+        Api.EffectExpired cardId authorId ->
+            let
+                card =
+                    Card.fromSeed cardId
+
+                expired effect =
+                    effect.name == card.name && effect.authorId == authorId
+            in
+            tryUpdate game
+                (\m ->
+                    { m | effects = Helper.listRemoveFirst expired m.effects } ! []
+                )
+                model
+
         Api.TradeCompleted mat ->
             tryUpdate (game |> goIn trade)
                 (\m -> { m | basket = mat } ! [])
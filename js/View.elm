@@ -1,5 +1,6 @@
 module View exposing (view)
 
+import BaseType exposing (GameSummary)
 import Card exposing (Card)
 import Helper
 import Html exposing (..)
@@ -45,6 +46,41 @@ welcomeView model =
                 ]
                 [ text "Join Game" ]
             ]
+        , lobbyView model.games
+        ]
+
+
+lobbyView : List GameSummary -> Html WelcomeMsg
+lobbyView games =
+    if List.isEmpty games then
+        div [] []
+
+    else
+        div [ class "box lobby" ] <|
+            div [ class "box-text" ] [ text "Or choose a game:" ]
+                :: List.map gameSummaryView games
+
+
+gameSummaryView : GameSummary -> Html WelcomeMsg
+gameSummaryView game =
+    button
+        [ class "box-button lobby-game"
+        , onClick (GameSelected game.name)
+        , disabled (not game.joinable)
+        ]
+        [ text game.name
+        , span [ class "lobby-game-info" ]
+            [ text
+                (toString game.players
+                    ++ (if game.players == 1 then
+                            " player, "
+
+                        else
+                            " players, "
+                       )
+                    ++ game.state
+                )
+            ]
         ]
 
 
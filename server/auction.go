@@ -0,0 +1,369 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// AuctionFormat determines how bidding works during the auction.
+type AuctionFormat string
+
+const (
+	// EnglishAuction is an open ascending auction. Everyone sees the bids,
+	// and the auction ends when nobody has bid for a while.
+	EnglishAuction AuctionFormat = "english"
+	// SealedBidAuction is a first-price sealed-bid auction. Bids are secret,
+	// and the highest bidder pays what they bid.
+	SealedBidAuction AuctionFormat = "sealed"
+	// VickreyAuction is a second-price sealed-bid auction. Bids are secret,
+	// and the highest bidder pays the second highest bid.
+	VickreyAuction AuctionFormat = "vickrey"
+	// DutchAuction is a descending price auction. The asking price drops
+	// until somebody accepts it by bidding.
+	DutchAuction AuctionFormat = "dutch"
+)
+
+const (
+	// SealedBidTime is how long players have to submit a sealed bid.
+	SealedBidTime time.Duration = 10 * time.Second
+	// DutchStepTime is how long the asking price holds before it drops.
+	DutchStepTime time.Duration = 1 * time.Second
+	// DutchPriceStep is how much the asking price drops each step.
+	DutchPriceStep = 1
+	// DutchStartingMultiple is the multiple of the card's starting bid at
+	// which the asking price begins.
+	DutchStartingMultiple = 4
+)
+
+// IsAuctionFormat returns true if f is a known AuctionFormat.
+func IsAuctionFormat(f AuctionFormat) bool {
+	switch f {
+	case EnglishAuction, SealedBidAuction, VickreyAuction, DutchAuction:
+		return true
+	}
+	return false
+}
+
+// AuctionController manages the auction stage, during which a number of cards
+// are sold one after another. Bids are held in escrow until the card is sold,
+// so that nobody can win a card that they can't pay for.
+type AuctionController struct {
+	name   GameState
+	game   *Game
+	format AuctionFormat
+	card   Card
+	bid    int
+	step   int
+	steps  int
+	winner User
+
+	// escrow holds the gold of each bidder whose bid is still standing.
+	escrow map[int]int
+	// bids are the sealed bids, in order of arrival.
+	bids []sealedBid
+	// price is the current asking price in a Dutch auction.
+	price int
+}
+
+type sealedBid struct {
+	user   User
+	amount int
+}
+
+func NewAuctionController(game *Game) *AuctionController {
+	return &AuctionController{
+		name:   AuctionState,
+		game:   game,
+		format: game.Config.AuctionFormat,
+		steps:  game.Config.NumberOfBids,
+		escrow: make(map[int]int),
+	}
+}
+
+// Name returns the name of the current state.
+func (s *AuctionController) Name() GameState { return s.name }
+
+// Begin is called when the state becomes active.
+func (s *AuctionController) Begin() {
+	s.issueCard()
+}
+
+func (s *AuctionController) issueCard() {
+	// When the auction begins, we need to choose a random card and broadcast
+	// it to the participants.
+	s.card = RandomCard(s.game.rand)
+	s.game.connection.Broadcast(
+		NewAuctionSeedMessage(s.card, s.format),
+	)
+
+	// Set a timeout, and update player clocks.
+	switch s.format {
+	case SealedBidAuction, VickreyAuction:
+		s.setClock(SealedBidTime)
+	case DutchAuction:
+		s.price = s.card.StartingBid * DutchStartingMultiple
+		s.game.connection.Broadcast(NewAskingPriceMessage(s.price))
+		s.setClock(DutchStepTime)
+	default:
+		s.setClock(s.game.Config.AuctionBidTime)
+	}
+}
+
+func (s *AuctionController) setClock(duration time.Duration) {
+	s.game.SetTimeout(duration)
+	s.game.connection.Broadcast(NewSetClockMessage(duration))
+}
+
+// End is called when the state is no longer active.
+func (s *AuctionController) End() {
+	// If the auction is cut short, everyone gets their gold back.
+	s.releaseAll()
+}
+
+// Timer is called when the current auction's clock runs out.
+func (s *AuctionController) Timer(tick time.Duration) {
+	switch s.format {
+	case SealedBidAuction, VickreyAuction:
+		s.closeSealed()
+	case DutchAuction:
+		s.lowerPrice()
+	default:
+		// The English auction is over once nobody has bid for a while.
+		s.closeAuction(s.winner, s.bid)
+	}
+}
+
+// closeSealed reveals the sealed bids and sells the card to the highest
+// bidder.
+func (s *AuctionController) closeSealed() {
+	if len(s.bids) == 0 {
+		s.closeAuction(nil, 0)
+		return
+	}
+
+	// The earliest bid wins a tie.
+	sort.SliceStable(s.bids, func(i, j int) bool {
+		return s.bids[i].amount > s.bids[j].amount
+	})
+	winner := s.bids[0]
+	price := winner.amount
+	if s.format == VickreyAuction {
+		price = s.card.StartingBid
+		if len(s.bids) > 1 {
+			price = s.bids[1].amount
+		}
+	}
+
+	s.game.connection.Broadcast(NewBidUpdatedMessage(price, winner.user))
+	s.closeAuction(winner.user, price)
+}
+
+// lowerPrice drops the asking price of a Dutch auction. If the price falls
+// below the starting bid, the card goes unsold.
+func (s *AuctionController) lowerPrice() {
+	s.price -= DutchPriceStep
+	if s.price < s.card.StartingBid {
+		s.closeAuction(nil, 0)
+		return
+	}
+
+	s.game.connection.Broadcast(NewAskingPriceMessage(s.price))
+	s.setClock(DutchStepTime)
+}
+
+// closeAuction sells the card to the winner at the given price, which is
+// taken from their escrow, and moves on to the next card.
+func (s *AuctionController) closeAuction(winner User, price int) {
+	if winner != nil {
+		s.collect(winner, price)
+		s.game.Account(winner).AddCard(s.card)
+		s.game.Account(winner).Round.AuctionsWon++
+		winner.Message(NewAuctionWonMessage())
+		s.game.SendBalance(winner)
+	}
+	s.releaseAll()
+
+	// Reset the bid and winner.
+	s.bid = 0
+	s.winner = nil
+	s.bids = nil
+	s.price = 0
+
+	s.step++
+	if s.step == s.steps {
+		// We've reached the end of the auction process. So let's change
+		// phases. The TradeState is next.
+		s.game.ChangeState(TradeState)
+	} else {
+		// Issue the next card.
+		s.issueCard()
+	}
+}
+
+// hold places the user's bid in escrow, replacing any bid of theirs which
+// is already held. It returns false if they can't afford it.
+func (s *AuctionController) hold(u User, amount int) bool {
+	account := s.game.Account(u)
+	if amount < 0 || amount > account.Gold+s.escrow[u.Id()] {
+		return false
+	}
+	account.Credit(s.escrow[u.Id()], nil)
+	account.Debit(amount, nil)
+	s.escrow[u.Id()] = amount
+	s.game.SendBalance(u)
+	return true
+}
+
+// release returns the user's escrowed gold to them.
+func (s *AuctionController) release(u User) {
+	amount, ok := s.escrow[u.Id()]
+	if !ok {
+		return
+	}
+	delete(s.escrow, u.Id())
+	s.game.Account(u).Credit(amount, nil)
+	s.game.SendBalance(u)
+}
+
+// releaseAll returns all escrowed gold.
+func (s *AuctionController) releaseAll() {
+	for id := range s.escrow {
+		if u := s.game.UserById(id); u != nil {
+			s.release(u)
+		}
+	}
+}
+
+// collect takes the price out of the user's escrow, and refunds the rest
+// without notifying them.
+func (s *AuctionController) collect(u User, price int) {
+	s.game.Account(u).Credit(s.escrow[u.Id()]-price, nil)
+	delete(s.escrow, u.Id())
+}
+
+// Resync sends a reconnecting player the card on sale and where the bidding
+// stands.
+func (s *AuctionController) Resync(u User) {
+	u.Message(NewAuctionSeedMessage(s.card, s.format))
+	switch s.format {
+	case SealedBidAuction, VickreyAuction:
+		if amount, ok := s.escrow[u.Id()]; ok {
+			u.Message(NewBidSealedMessage(amount))
+		}
+	case DutchAuction:
+		u.Message(NewAskingPriceMessage(s.price))
+	default:
+		if s.winner != nil {
+			u.Message(NewBidUpdatedMessage(s.bid, s.winner))
+		}
+	}
+}
+
+// Snapshot adds the card on sale and where the bidding stands.
+func (s *AuctionController) Snapshot(u User, snapshot *StateSnapshotMessage) {
+	card := s.card
+	snapshot.Card = &card
+	snapshot.Format = s.format
+	switch s.format {
+	case SealedBidAuction, VickreyAuction:
+		snapshot.SealedBid = s.escrow[u.Id()]
+	case DutchAuction:
+		snapshot.AskingPrice = s.price
+	default:
+		snapshot.Bid = s.bid
+		if s.winner != nil {
+			snapshot.Winner = s.winner.Name()
+			snapshot.WinnerId = s.winner.Id()
+		}
+	}
+}
+
+// checkBid returns the reason that a bid is invalid, or an empty string if
+// the bid is allowed.
+func (s *AuctionController) checkBid(amount int) string {
+	if amount < s.card.StartingBid {
+		return "Your bid must be at least the starting bid."
+	}
+	switch s.format {
+	case SealedBidAuction, VickreyAuction:
+		// Sealed bids don't need to beat anybody else's.
+	case DutchAuction:
+		if amount < s.price {
+			return "Your bid must match the asking price."
+		}
+	default:
+		if amount <= s.bid {
+			return "Your bid must be higher than the current bid."
+		}
+	}
+	return ""
+}
+
+// RecieveMessage is called when a new message is sent by a user.
+func (s *AuctionController) RecieveMessage(u User, m Message) {
+	switch msg := m.(type) {
+	case BidMessage:
+		reason := s.checkBid(msg.Amount)
+		if reason == "" {
+			reason = s.placeBid(u, msg.Amount)
+		}
+		if reason != "" {
+			u.Message(NewBidRejectedMessage(msg.Amount, reason))
+		}
+	case LeaveMessage:
+		// A player who leaves can't win, and their account is already gone.
+		delete(s.escrow, u.Id())
+		for i, b := range s.bids {
+			if b.user == u {
+				s.bids = append(s.bids[:i], s.bids[i+1:]...)
+				break
+			}
+		}
+		if s.winner == u {
+			s.bid = 0
+			s.winner = nil
+			s.game.connection.Broadcast(NewBidUpdatedMessage(0, nil))
+		}
+	}
+}
+
+// placeBid places a valid bid according to the auction format. It returns
+// the reason that the bid was refused, if it couldn't be placed.
+func (s *AuctionController) placeBid(u User, amount int) string {
+	switch s.format {
+	case SealedBidAuction, VickreyAuction:
+		if !s.hold(u, amount) {
+			return "You can't afford that bid."
+		}
+		// Players may change their sealed bid until the auction closes.
+		for i, b := range s.bids {
+			if b.user == u {
+				s.bids = append(s.bids[:i], s.bids[i+1:]...)
+				break
+			}
+		}
+		s.bids = append(s.bids, sealedBid{user: u, amount: amount})
+		u.Message(NewBidSealedMessage(amount))
+	case DutchAuction:
+		// The first player to accept the asking price wins immediately.
+		if !s.hold(u, s.price) {
+			return "You can't afford that bid."
+		}
+		s.game.connection.Broadcast(NewBidUpdatedMessage(s.price, u))
+		s.closeAuction(u, s.price)
+	default:
+		if !s.hold(u, amount) {
+			return "You can't afford that bid."
+		}
+		if s.winner != nil && s.winner != u {
+			s.release(s.winner)
+		}
+		s.bid = amount
+		s.winner = u
+
+		// Update everyone on the new bid and winner.
+		s.game.connection.Broadcast(NewBidUpdatedMessage(s.bid, u))
+		s.setClock(s.game.Config.AuctionBidTime)
+	}
+	return ""
+}
@@ -0,0 +1,118 @@
+package main
+
+import "testing"
+
+func newTestAuction(format AuctionFormat) (*Game, *AuctionController) {
+	connection := TestConnection{}
+	game := NewGame("g", &connection, DefaultGameConfig())
+	game.Config.AuctionFormat = format
+	ctrl := NewAuctionController(game)
+	game.state = ctrl
+	ctrl.Begin()
+	return game, ctrl
+}
+
+func TestSealedBidAuction(t *testing.T) {
+	game, ctrl := newTestAuction(SealedBidAuction)
+
+	u1 := &TestUser{name: "u1"}
+	u2 := &TestUser{name: "u2"}
+	ctrl.RecieveMessage(u1, NewBidMessage(10))
+	ctrl.RecieveMessage(u2, NewBidMessage(8))
+
+	// A lower sealed bid is still accepted, and held in escrow.
+	if gold := game.Account(u2).Gold; gold != StartingGold-8 {
+		t.Errorf("u2 has %v gold, want %v", gold, StartingGold-8)
+	}
+
+	// Bidders can change their bid before the auction closes.
+	ctrl.RecieveMessage(u2, NewBidMessage(12))
+	if gold := game.Account(u2).Gold; gold != StartingGold-12 {
+		t.Errorf("u2 has %v gold, want %v", gold, StartingGold-12)
+	}
+
+	game.Tick(2 * SealedBidTime)
+
+	// The highest bidder pays their own bid, and the loser is refunded.
+	if gold := game.Account(u2).Gold; gold != StartingGold-12 {
+		t.Errorf("u2 has %v gold, want %v", gold, StartingGold-12)
+	}
+	if n := len(game.Account(u2).Cards); n != 1 {
+		t.Errorf("u2 has %v cards, want 1", n)
+	}
+	if gold := game.Account(u1).Gold; gold != StartingGold {
+		t.Errorf("u1 has %v gold, want %v", gold, StartingGold)
+	}
+	if ctrl.step != 1 {
+		t.Errorf("ctrl.step = %v, want 1", ctrl.step)
+	}
+}
+
+func TestVickreyAuction(t *testing.T) {
+	game, ctrl := newTestAuction(VickreyAuction)
+
+	u1 := &TestUser{name: "u1"}
+	u2 := &TestUser{name: "u2"}
+	ctrl.RecieveMessage(u1, NewBidMessage(10))
+	ctrl.RecieveMessage(u2, NewBidMessage(20))
+
+	game.Tick(2 * SealedBidTime)
+
+	// The highest bidder pays the second highest bid.
+	if gold := game.Account(u2).Gold; gold != StartingGold-10 {
+		t.Errorf("u2 has %v gold, want %v", gold, StartingGold-10)
+	}
+	if gold := game.Account(u1).Gold; gold != StartingGold {
+		t.Errorf("u1 has %v gold, want %v", gold, StartingGold)
+	}
+
+	// A lone bidder pays the starting bid.
+	ctrl.RecieveMessage(u1, NewBidMessage(10))
+	card := ctrl.card
+	game.Tick(4 * SealedBidTime)
+	if gold := game.Account(u1).Gold; gold != StartingGold-card.StartingBid {
+		t.Errorf("u1 has %v gold, want %v", gold, StartingGold-card.StartingBid)
+	}
+}
+
+func TestDutchAuction(t *testing.T) {
+	game, ctrl := newTestAuction(DutchAuction)
+	start := ctrl.card.StartingBid * DutchStartingMultiple
+	if ctrl.price != start {
+		t.Errorf("ctrl.price = %v, want %v", ctrl.price, start)
+	}
+
+	// The price drops over time.
+	game.Tick(DutchStepTime + 1)
+	if ctrl.price != start-DutchPriceStep {
+		t.Errorf("ctrl.price = %v, want %v", ctrl.price, start-DutchPriceStep)
+	}
+
+	// Bidding below the asking price doesn't win.
+	u := &TestUser{name: "u"}
+	ctrl.RecieveMessage(u, NewBidMessage(ctrl.price-1))
+	if ctrl.step != 0 {
+		t.Errorf("Bid below the asking price won the auction")
+	}
+
+	// The first player to accept the price wins straight away.
+	price := ctrl.price
+	ctrl.RecieveMessage(u, NewBidMessage(price))
+	if ctrl.step != 1 {
+		t.Errorf("ctrl.step = %v, want 1", ctrl.step)
+	}
+	if gold := game.Account(u).Gold; gold != StartingGold-price {
+		t.Errorf("u has %v gold, want %v", gold, StartingGold-price)
+	}
+
+	// If nobody accepts, the card goes unsold once the price drops below
+	// the starting bid.
+	tick := 2 * DutchStepTime
+	for ctrl.step == 1 {
+		game.Tick(tick)
+		tick += DutchStepTime + 1
+	}
+	if n := len(game.Account(u).Cards); n != 1 {
+		t.Errorf("u has %v cards, want 1", n)
+	}
+}
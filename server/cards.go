@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// InfiniteCharges is the number of charges on a card which can be activated
+// any number of times.
+const InfiniteCharges = -1
+
+// Card is a card which can be won at auction and activated later on. It
+// mirrors the Card type in Card.elm.
+type Card struct {
+	Id                int                       `json:"id"`
+	Name              string                    `json:"name"`
+	Description       string                    `json:"description"`
+	StartingBid       int                       `json:"starting_bid"`
+	YieldRateModifier map[CommodityType]float64 `json:"yield_rate_modifier"`
+	ResourceCost      map[CommodityType]int     `json:"resource_cost"`
+	Charges           int                       `json:"charges"`
+}
+
+// AllCards is the catalog of every card, where each card's Id is its index.
+// It must be kept in the same order as allCards in Card.elm.
+var AllCards []Card = catalog()
+
+func catalog() []Card {
+	cards := []Card{blueberryJam()}
+	for _, c := range []CommodityType{Blueberry, Tomato, Corn, Purple} {
+		cards = append(cards, famine(c))
+	}
+
+	for i := range cards {
+		cards[i].Id = i
+	}
+	return cards
+}
+
+// baseCard is the card which all other cards are derived from.
+func baseCard() Card {
+	modifier := make(map[CommodityType]float64)
+	for _, c := range AllCommodities {
+		modifier[c] = 1
+	}
+	return Card{
+		Name:              "Untitled",
+		Description:       "No description",
+		StartingBid:       3,
+		YieldRateModifier: modifier,
+		ResourceCost:      make(map[CommodityType]int),
+		Charges:           1,
+	}
+}
+
+func blueberryJam() Card {
+	card := baseCard()
+	card.Name = "Blueberry Jam"
+	card.ResourceCost[Blueberry] = 10
+	return card
+}
+
+func famine(c CommodityType) Card {
+	card := baseCard()
+	name := string(c)
+	card.Name = strings.ToUpper(name[:1]) + name[1:] + " Famine"
+	card.Description = "When activated, the factories will yield less."
+	card.YieldRateModifier[c] = 0.8
+	card.ResourceCost[Tomato] = 5
+	return card
+}
+
+// LookupCard returns the card with the given id, if it exists.
+func LookupCard(id int) (Card, bool) {
+	if id < 0 || id >= len(AllCards) {
+		return Card{}, false
+	}
+	return AllCards[id], true
+}
+
+// RandomCard chooses a card from the catalog at random.
+func RandomCard(r *rand.Rand) Card {
+	return AllCards[r.Intn(len(AllCards))]
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestCardCatalog(t *testing.T) {
+	// The catalog must match allCards in Card.elm.
+	names := []string{
+		"Blueberry Jam",
+		"Blueberry Famine",
+		"Tomato Famine",
+		"Corn Famine",
+		"Purple Famine",
+	}
+	if len(AllCards) != len(names) {
+		t.Fatalf("len(AllCards) = %v, want %v", len(AllCards), len(names))
+	}
+	for i, name := range names {
+		card, ok := LookupCard(i)
+		if !ok {
+			t.Errorf("LookupCard(%v) failed", i)
+		}
+		if card.Id != i || card.Name != name {
+			t.Errorf("LookupCard(%v) = %v %q, want %v %q", i, card.Id, card.Name, i, name)
+		}
+	}
+
+	if _, ok := LookupCard(len(AllCards)); ok {
+		t.Errorf("LookupCard(%v) succeeded, expected failure", len(AllCards))
+	}
+
+	famine := AllCards[3]
+	if famine.YieldRateModifier[Corn] != 0.8 || famine.YieldRateModifier[Tomato] != 1 {
+		t.Errorf("Corn Famine has yield modifier %v", famine.YieldRateModifier)
+	}
+}
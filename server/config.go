@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// MaxStageTime is the longest that any timer in the game may be set to.
+	MaxStageTime time.Duration = 10 * time.Minute
+	// MaxConfigPlayers is the largest MinPlayers that a game may ask for.
+	MaxConfigPlayers = 20
+	// MaxConfigBids is the largest number of cards that may be auctioned
+	// each round.
+	MaxConfigBids = 20
+)
+
+// JoinPolicy decides what happens to a player who arrives once the game has
+// begun.
+type JoinPolicy string
+
+const (
+	// JoinAsPlayer lets the player join the game in progress.
+	JoinAsPlayer JoinPolicy = "join"
+	// JoinAsSpectator lets the player watch the game in progress.
+	JoinAsSpectator JoinPolicy = "spectate"
+	// RejectJoin turns the player away.
+	RejectJoin JoinPolicy = "reject"
+	// QueueJoin holds the player until the next game begins.
+	QueueJoin JoinPolicy = "queue"
+)
+
+// IsJoinPolicy returns true if p is a known JoinPolicy.
+func IsJoinPolicy(p JoinPolicy) bool {
+	switch p {
+	case JoinAsPlayer, JoinAsSpectator, RejectJoin, QueueJoin:
+		return true
+	}
+	return false
+}
+
+// GameConfig holds the settings of a single game. It is chosen when the
+// game is created, and can be changed by the host while the game is
+// waiting to begin.
+type GameConfig struct {
+	ProductionTimeout time.Duration
+	AuctionBidTime    time.Duration
+	NumberOfBids      int
+	TradingStageTime  time.Duration
+	TradeTimeout      time.Duration
+	SummaryStageTime  time.Duration
+	MinPlayers        int
+	GoldTarget        int
+	MaxRounds         int
+	AuctionFormat     AuctionFormat
+	JoinPolicy        JoinPolicy
+}
+
+// DefaultGameConfig returns the settings which are used unless the game
+// asks for something else.
+func DefaultGameConfig() GameConfig {
+	return GameConfig{
+		ProductionTimeout: ProductionTimeout,
+		AuctionBidTime:    AuctionBidTime,
+		NumberOfBids:      NumberOfBids,
+		TradingStageTime:  TradingStageTime,
+		TradeTimeout:      TradeTimeout,
+		SummaryStageTime:  SummaryStageTime,
+		MinPlayers:        MinPlayers,
+		GoldTarget:        GoldTarget,
+		MaxRounds:         MaxRounds,
+		AuctionFormat:     EnglishAuction,
+		JoinPolicy:        JoinAsSpectator,
+	}
+}
+
+// Validate returns an error explaining what is wrong with the config, or nil
+// if it is fine.
+func (c GameConfig) Validate() error {
+	durations := []time.Duration{
+		c.ProductionTimeout, c.AuctionBidTime, c.TradingStageTime,
+		c.TradeTimeout, c.SummaryStageTime,
+	}
+	for _, d := range durations {
+		if d <= 0 || d > MaxStageTime {
+			return fmt.Errorf("Stage times must be positive, and at most %v.", MaxStageTime)
+		}
+	}
+	if c.NumberOfBids < 1 || c.NumberOfBids > MaxConfigBids {
+		return fmt.Errorf("The number of bids must be between 1 and %v.", MaxConfigBids)
+	}
+	if c.MinPlayers < 1 || c.MinPlayers > MaxConfigPlayers {
+		return fmt.Errorf("The minimum number of players must be between 1 and %v.", MaxConfigPlayers)
+	}
+	if c.GoldTarget < 0 || c.MaxRounds < 0 {
+		return errors.New("The gold target and round limit can't be negative.")
+	}
+	if c.GoldTarget == 0 && c.MaxRounds == 0 {
+		return errors.New("The game needs a gold target or a round limit.")
+	}
+	if !IsAuctionFormat(c.AuctionFormat) {
+		return errors.New("Unknown auction format.")
+	}
+	if !IsJoinPolicy(c.JoinPolicy) {
+		return errors.New("Unknown join policy.")
+	}
+	return nil
+}
+
+// gameConfigJSON is the form of a GameConfig sent between the client and
+// the server. Times are in milliseconds, like the clock.
+type gameConfigJSON struct {
+	ProductionTimeout int           `json:"production_timeout"`
+	AuctionBidTime    int           `json:"auction_bid_time"`
+	NumberOfBids      int           `json:"number_of_bids"`
+	TradingStageTime  int           `json:"trading_stage_time"`
+	TradeTimeout      int           `json:"trade_timeout"`
+	SummaryStageTime  int           `json:"summary_stage_time"`
+	MinPlayers        int           `json:"min_players"`
+	GoldTarget        int           `json:"gold_target"`
+	MaxRounds         int           `json:"max_rounds"`
+	AuctionFormat     AuctionFormat `json:"auction_format"`
+	JoinPolicy        JoinPolicy    `json:"join_policy"`
+}
+
+func milliseconds(d time.Duration) int {
+	return int(d / time.Millisecond)
+}
+
+// MarshalJSON encodes the config with times in milliseconds.
+func (c GameConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(gameConfigJSON{
+		ProductionTimeout: milliseconds(c.ProductionTimeout),
+		AuctionBidTime:    milliseconds(c.AuctionBidTime),
+		NumberOfBids:      c.NumberOfBids,
+		TradingStageTime:  milliseconds(c.TradingStageTime),
+		TradeTimeout:      milliseconds(c.TradeTimeout),
+		SummaryStageTime:  milliseconds(c.SummaryStageTime),
+		MinPlayers:        c.MinPlayers,
+		GoldTarget:        c.GoldTarget,
+		MaxRounds:         c.MaxRounds,
+		AuctionFormat:     c.AuctionFormat,
+		JoinPolicy:        c.JoinPolicy,
+	})
+}
+
+// UnmarshalJSON decodes a config with times in milliseconds. Settings which
+// are missing keep their current value, so a client only needs to send the
+// settings that it wants to change.
+func (c *GameConfig) UnmarshalJSON(data []byte) error {
+	m := gameConfigJSON{
+		ProductionTimeout: milliseconds(c.ProductionTimeout),
+		AuctionBidTime:    milliseconds(c.AuctionBidTime),
+		NumberOfBids:      c.NumberOfBids,
+		TradingStageTime:  milliseconds(c.TradingStageTime),
+		TradeTimeout:      milliseconds(c.TradeTimeout),
+		SummaryStageTime:  milliseconds(c.SummaryStageTime),
+		MinPlayers:        c.MinPlayers,
+		GoldTarget:        c.GoldTarget,
+		MaxRounds:         c.MaxRounds,
+		AuctionFormat:     c.AuctionFormat,
+		JoinPolicy:        c.JoinPolicy,
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	*c = GameConfig{
+		ProductionTimeout: time.Duration(m.ProductionTimeout) * time.Millisecond,
+		AuctionBidTime:    time.Duration(m.AuctionBidTime) * time.Millisecond,
+		NumberOfBids:      m.NumberOfBids,
+		TradingStageTime:  time.Duration(m.TradingStageTime) * time.Millisecond,
+		TradeTimeout:      time.Duration(m.TradeTimeout) * time.Millisecond,
+		SummaryStageTime:  time.Duration(m.SummaryStageTime) * time.Millisecond,
+		MinPlayers:        m.MinPlayers,
+		GoldTarget:        m.GoldTarget,
+		MaxRounds:         m.MaxRounds,
+		AuctionFormat:     m.AuctionFormat,
+		JoinPolicy:        m.JoinPolicy,
+	}
+	return nil
+}
+
+// ParseGameConfig reads a config from the /join query parameters, which use
+// the same names as the JSON encoding. Times may be given in milliseconds,
+// or as a duration such as "30s". Settings which aren't given keep their
+// default values. The config is validated.
+func ParseGameConfig(params url.Values) (GameConfig, error) {
+	c := DefaultGameConfig()
+	durations := map[string]*time.Duration{
+		"production_timeout": &c.ProductionTimeout,
+		"auction_bid_time":   &c.AuctionBidTime,
+		"trading_stage_time": &c.TradingStageTime,
+		"trade_timeout":      &c.TradeTimeout,
+		"summary_stage_time": &c.SummaryStageTime,
+	}
+	for key, d := range durations {
+		v := params.Get(key)
+		if v == "" {
+			continue
+		}
+		if ms, err := strconv.Atoi(v); err == nil {
+			*d = time.Duration(ms) * time.Millisecond
+		} else if parsed, err := time.ParseDuration(v); err == nil {
+			*d = parsed
+		} else {
+			return c, fmt.Errorf("Invalid %v: %q", key, v)
+		}
+	}
+
+	ints := map[string]*int{
+		"number_of_bids": &c.NumberOfBids,
+		"min_players":    &c.MinPlayers,
+		"gold_target":    &c.GoldTarget,
+		"max_rounds":     &c.MaxRounds,
+	}
+	for key, n := range ints {
+		v := params.Get(key)
+		if v == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("Invalid %v: %q", key, v)
+		}
+		*n = parsed
+	}
+
+	if v := params.Get("auction_format"); v != "" {
+		c.AuctionFormat = AuctionFormat(v)
+	}
+	if v := params.Get("join_policy"); v != "" {
+		c.JoinPolicy = JoinPolicy(v)
+	}
+	return c, c.Validate()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseGameConfig(t *testing.T) {
+	params := url.Values{}
+	params.Set("production_timeout", "30s")
+	params.Set("auction_bid_time", "2500")
+	params.Set("min_players", "3")
+	params.Set("auction_format", "dutch")
+	params.Set("join_policy", "queue")
+	config, err := ParseGameConfig(params)
+	if err != nil {
+		t.Fatalf("ParseGameConfig: %v", err)
+	}
+
+	want := DefaultGameConfig()
+	want.ProductionTimeout = 30 * time.Second
+	want.AuctionBidTime = 2500 * time.Millisecond
+	want.MinPlayers = 3
+	want.AuctionFormat = DutchAuction
+	want.JoinPolicy = QueueJoin
+	if config != want {
+		t.Errorf("ParseGameConfig = %+v, want %+v", config, want)
+	}
+
+	for _, bad := range []string{
+		"min_players=0", "number_of_bids=x", "trade_timeout=-1",
+		"auction_format=silent", "gold_target=0&max_rounds=0", "summary_stage_time=1h", "join_policy=maybe",
+	} {
+		params, _ := url.ParseQuery(bad)
+		if _, err := ParseGameConfig(params); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestGameConfigJSON(t *testing.T) {
+	data, err := json.Marshal(DefaultGameConfig())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	config := GameConfig{}
+	if err := json.Unmarshal(data, &config); err != nil || config != DefaultGameConfig() {
+		t.Errorf("Round trip: got %+v, %v", config, err)
+	}
+
+	// Settings which aren't given are left alone.
+	if err := json.Unmarshal([]byte(`{"max_rounds": 3, "trading_stage_time": 20000}`), &config); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	want := DefaultGameConfig()
+	want.MaxRounds = 3
+	want.TradingStageTime = 20 * time.Second
+	if config != want {
+		t.Errorf("Partial config = %+v, want %+v", config, want)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	game, connection, host, guest := newTestHostGame()
+
+	connection.broadcastLog = nil
+	game.RecieveMessage(host, NewConfigureMessage(`{"number_of_bids": 5}`))
+	want := DefaultGameConfig()
+	want.NumberOfBids = 5
+	if game.Config != want {
+		t.Errorf("game.Config = %+v, want %+v", game.Config, want)
+	}
+	expected := TestConnection{}
+	expected.Broadcast(NewConfigChangedMessage(want))
+	if diff := CompareBroadcastLog(*connection, expected); diff != "" {
+		t.Errorf("Configure: %v", diff)
+	}
+
+	// Invalid settings are refused.
+	host.messageLog = nil
+	game.RecieveMessage(host, NewConfigureMessage(`{"min_players": 0}`))
+	expectedHost := &TestUser{}
+	expectedHost.Message(NewErrorMessage(fmt.Sprintf("The minimum number of players must be between 1 and %v.", MaxConfigPlayers)))
+	if diff := CompareMessageLog(host, expectedHost); diff != "" {
+		t.Errorf("Invalid config: %v", diff)
+	}
+
+	// Once the game begins, the settings are fixed.
+	game.RecieveMessage(host, NewReadyMessage(true))
+	game.RecieveMessage(guest, NewReadyMessage(true))
+	if game.state.Name() != ProductionState {
+		t.Fatalf("game.state.Name() = %v, want %v", game.state.Name(), ProductionState)
+	}
+	host.messageLog = nil
+	game.RecieveMessage(host, NewConfigureMessage(`{"number_of_bids": 1}`))
+	expectedHost = &TestUser{}
+	expectedHost.Message(NewErrorMessage("The game has already begun."))
+	if diff := CompareMessageLog(host, expectedHost); diff != "" {
+		t.Errorf("Configure after start: %v", diff)
+	}
+}
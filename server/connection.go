@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// SendQueueSize is the number of messages which can be waiting to be
+	// written to a connection. A client which falls further behind than
+	// this is dropped.
+	SendQueueSize = 64
+	// WriteTimeout is how long a single write to a client may take.
+	WriteTimeout time.Duration = 10 * time.Second
+)
+
+// PongTimeout is how long a client may go without responding to a ping
+// before it is considered dead. It is a variable so that tests can shorten
+// it; connections keep the value they were created with.
+var PongTimeout time.Duration = 60 * time.Second
+
+// Connection is a single websocket connection to a player. Messages are
+// queued and written by a dedicated thread, so that a slow client can't
+// hold up the game thread.
+type Connection struct {
+	ws          *websocket.Conn
+	send        chan []byte
+	done        chan struct{}
+	closeOnce   sync.Once
+	pongTimeout time.Duration
+}
+
+// NewConnection wraps a websocket, and starts the thread which writes to
+// it and keeps it alive.
+func NewConnection(ws *websocket.Conn) *Connection {
+	c := &Connection{
+		ws:          ws,
+		send:        make(chan []byte, SendQueueSize),
+		done:        make(chan struct{}),
+		pongTimeout: PongTimeout,
+	}
+	ws.SetReadDeadline(time.Now().Add(c.pongTimeout))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(c.pongTimeout))
+	})
+	go c.writePump()
+	return c
+}
+
+// Write queues a value to be sent to the client as JSON. If the queue is
+// full, the client can't keep up, so the connection is closed.
+func (c *Connection) Write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-c.done:
+		return errors.New("connection is closed")
+	default:
+	}
+
+	select {
+	case c.send <- data:
+		return nil
+	default:
+		log.Printf("Websocket[addr=%v] is too slow, dropping it", c.ws.RemoteAddr())
+		c.Close()
+		return errors.New("send queue is full")
+	}
+}
+
+// ReadMessage reads the next message from the client. Any message from the
+// client shows that it is still alive.
+func (c *Connection) ReadMessage() (int, []byte, error) {
+	t, data, err := c.ws.ReadMessage()
+	if err == nil {
+		c.ws.SetReadDeadline(time.Now().Add(c.pongTimeout))
+	}
+	return t, data, err
+}
+
+// RemoteAddr returns the client's network address.
+func (c *Connection) RemoteAddr() string {
+	return c.ws.RemoteAddr().String()
+}
+
+// Close closes the connection. Messages which haven't been written yet are
+// discarded. It is safe to call more than once.
+func (c *Connection) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.ws.Close()
+	})
+}
+
+// CloseWhenSent closes the connection once the messages which are already
+// queued have been written.
+func (c *Connection) CloseWhenSent() {
+	select {
+	case c.send <- nil:
+	default:
+		c.Close()
+	}
+}
+
+// writePump writes queued messages, and pings the client periodically,
+// until the connection is closed. Clients are pinged well within the pong
+// timeout.
+func (c *Connection) writePump() {
+	ticker := time.NewTicker(c.pongTimeout * 9 / 10)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case data := <-c.send:
+			c.ws.SetWriteDeadline(time.Now().Add(WriteTimeout))
+			if data == nil {
+				// CloseWhenSent was called.
+				c.ws.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				c.Close()
+				return
+			}
+			if err := c.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+				log.Printf("Websocket[addr=%v] write error: %v", c.ws.RemoteAddr(), err)
+				c.Close()
+				return
+			}
+		case <-ticker.C:
+			c.ws.SetWriteDeadline(time.Now().Add(WriteTimeout))
+			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				log.Printf("Websocket[addr=%v] ping error: %v", c.ws.RemoteAddr(), err)
+				c.Close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+const (
+	// BaseYield is the number of units each factory produces per round
+	// when no effects are active.
+	BaseYield float64 = 1.00
+	// EffectRounds is the number of rounds that an effect lasts.
+	EffectRounds = 2
+)
+
+// Effect is an activated card which modifies the yield of every player's
+// factories for a number of rounds.
+type Effect struct {
+	CardId            int                       `json:"card_id"`
+	Name              string                    `json:"name"`
+	Author            string                    `json:"author"`
+	AuthorId          int                       `json:"author_id"`
+	YieldRateModifier map[CommodityType]float64 `json:"yield_rate_modifier"`
+	RoundsLeft        int                       `json:"rounds_left"`
+}
+
+// NewEffect creates an effect from a card activated by the author.
+func NewEffect(card Card, author User, rounds int) Effect {
+	return Effect{
+		CardId:            card.Id,
+		Name:              card.Name,
+		Author:            author.Name(),
+		AuthorId:          author.Id(),
+		YieldRateModifier: card.YieldRateModifier,
+		RoundsLeft:        rounds,
+	}
+}
+
+// Effects is the registry of effects which are currently active.
+type Effects struct {
+	active []Effect
+}
+
+// Add registers a newly activated effect.
+func (e *Effects) Add(effect Effect) {
+	e.active = append(e.active, effect)
+}
+
+// Active returns every active effect, in order of activation.
+func (e *Effects) Active() []Effect {
+	return append([]Effect{}, e.active...)
+}
+
+// Yield computes the yield of each commodity with all active effects
+// applied.
+func (e *Effects) Yield() map[CommodityType]float64 {
+	yield := make(map[CommodityType]float64)
+	for _, c := range AllCommodities {
+		yield[c] = BaseYield
+		for _, effect := range e.active {
+			if m, ok := effect.YieldRateModifier[c]; ok {
+				yield[c] *= m
+			}
+		}
+	}
+	return yield
+}
+
+// EndRound counts down every effect by one round, and removes and returns
+// the effects which have expired.
+func (e *Effects) EndRound() []Effect {
+	var active, expired []Effect
+	for _, effect := range e.active {
+		effect.RoundsLeft--
+		if effect.RoundsLeft > 0 {
+			active = append(active, effect)
+		} else {
+			expired = append(expired, effect)
+		}
+	}
+	e.active = active
+	return expired
+}
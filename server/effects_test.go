@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestEffectsYield(t *testing.T) {
+	e := Effects{}
+	a := &TestUser{name: "a"}
+	e.Add(NewEffect(AllCards[3], a, 1))
+	e.Add(NewEffect(AllCards[3], &TestUser{name: "b"}, 2))
+
+	yield := e.Yield()
+	if want := BaseYield * 0.8 * 0.8; yield[Corn] != want {
+		t.Errorf("yield[Corn] = %v, want %v", yield[Corn], want)
+	}
+	if yield[Tomato] != BaseYield {
+		t.Errorf("yield[Tomato] = %v, want %v", yield[Tomato], BaseYield)
+	}
+
+	expired := e.EndRound()
+	if len(expired) != 1 || expired[0].AuthorId != a.Id() {
+		t.Errorf("e.EndRound() = %v, expected a's effect to expire", expired)
+	}
+	if active := e.Active(); len(active) != 1 || active[0].RoundsLeft != 1 {
+		t.Errorf("e.Active() = %v, expected b's effect with 1 round left", active)
+	}
+
+	e.EndRound()
+	if yield := e.Yield(); yield[Corn] != BaseYield {
+		t.Errorf("yield[Corn] = %v, want %v", yield[Corn], BaseYield)
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"log"
+	"math/rand"
+	"sort"
 	"time"
 )
 
@@ -19,6 +21,9 @@ var AllCommodities []CommodityType = []CommodityType{Tomato, Blueberry, Corn, Pu
 // User represents a single connection to a player, e.g. a websocket.
 type User interface {
 	Message(message Message) error
+	// Id is a unique number assigned to the player by the server. Names
+	// may be shared, so the Id is used to tell players apart.
+	Id() int
 	Name() string
 	SetName(name string)
 }
@@ -27,6 +32,11 @@ type User interface {
 // used to broadcast messages to all players.
 type GameConnection interface {
 	Broadcast(message Message) error
+	// Finish is called once the game is over, so that the connection can
+	// be cleaned up.
+	Finish()
+	// Kick disconnects a user who has been removed from the game.
+	Kick(user User)
 }
 
 // Game represents the state of an individual game instance.
@@ -36,24 +46,44 @@ type Game struct {
 	state       StateController
 	nextTimeout time.Duration
 	tick        time.Duration
-	MinPlayers  int
+	clock       time.Duration
+	paused      bool
+	host        int
+	Config      GameConfig
 	Yield       map[CommodityType]float64
+	users       map[int]User
+	accounts    map[int]*Account
+	spectators  map[int]User
+	market      *Market
+	effects     Effects
+	round       int
+
+	// rand is the game's own source of randomness, so that a game can be
+	// replayed from its seed and its events.
+	rand *rand.Rand
+	seed int64
 }
 
-// NewGame constructs a game.
-func NewGame(name string, connection GameConnection) *Game {
+// NewGame constructs a game with the given config, which should already
+// have been validated.
+func NewGame(name string, connection GameConnection, config GameConfig) *Game {
 	game := Game{
 		name:       name,
 		connection: connection,
 		state:      nil,
 		Yield:      make(map[CommodityType]float64),
-		MinPlayers: MinPlayers,
+		Config:     config,
+		users:      make(map[int]User),
+		accounts:   make(map[int]*Account),
+		spectators: make(map[int]User),
+		market:     NewMarket(),
 	}
+	game.SetSeed(rand.Int63())
 	game.state = NewStateController(&game, WaitingState)
 	game.state.Begin()
 
 	for _, c := range AllCommodities {
-		game.Yield[c] = 1.00
+		game.Yield[c] = BaseYield
 	}
 
 	return &game
@@ -67,15 +97,37 @@ func (g *Game) SetTimeout(duration time.Duration) {
 	g.nextTimeout = g.tick + duration
 }
 
+// Seed returns the seed of the game's random number generator.
+func (g *Game) Seed() int64 {
+	return g.seed
+}
+
+// SetSeed restarts the game's random number generator from the seed.
+func (g *Game) SetSeed(seed int64) {
+	g.seed = seed
+	g.rand = rand.New(rand.NewSource(seed))
+}
+
 // GetTime returns the current time since the game began.
 func (g *Game) GetTime() time.Duration {
 	return g.tick
 }
 
 // Tick is called each time that the tick interval elapses.
-func (g *Game) Tick(time time.Duration) {
+// While the game is paused, the clock passes without the game noticing.
+func (g *Game) Tick(clock time.Duration) {
+	elapsed := clock - g.clock
+	g.clock = clock
+	if g.paused {
+		return
+	}
+	time := g.tick + elapsed
 	g.tick = time
 
+	if t, ok := g.state.(TickingController); ok {
+		t.Tick(time)
+	}
+
 	// If a timer is currently set, notify the state controller.
 	if g.nextTimeout != 0 && time > g.nextTimeout {
 		g.nextTimeout = 0
@@ -83,23 +135,312 @@ func (g *Game) Tick(time time.Duration) {
 	}
 }
 
+// Account returns the ledger entry for the user, opening a new account if
+// they don't have one yet.
+func (g *Game) Account(user User) *Account {
+	a, ok := g.accounts[user.Id()]
+	if !ok {
+		a = NewAccount()
+		g.accounts[user.Id()] = a
+		g.users[user.Id()] = user
+	}
+	return a
+}
+
+// Users returns every user with an account, ordered by name, and then by
+// Id for players who share a name.
+func (g *Game) Users() []User {
+	var users []User
+	for _, u := range g.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Name() != users[j].Name() {
+			return users[i].Name() < users[j].Name()
+		}
+		return users[i].Id() < users[j].Id()
+	})
+	return users
+}
+
+// UserById returns the user with an account under the given Id, or nil if
+// there isn't one.
+func (g *Game) UserById(id int) User {
+	return g.users[id]
+}
+
+// UserByName returns the first user with an account under the given name,
+// or nil if there isn't one.
+func (g *Game) UserByName(name string) User {
+	for _, u := range g.Users() {
+		if u.Name() == name {
+			return u
+		}
+	}
+	return nil
+}
+
+// Standings returns each player's results for the current round, ordered
+// from the most gold to the least.
+func (g *Game) Standings() []Standing {
+	var standings []Standing
+	for _, u := range g.Users() {
+		a := g.Account(u)
+		standings = append(standings, Standing{
+			Id:          u.Id(),
+			Name:        u.Name(),
+			Gold:        a.Gold,
+			GoldEarned:  a.Gold - a.Round.StartingGold,
+			Production:  a.Round.Production,
+			AuctionsWon: a.Round.AuctionsWon,
+			Trades:      a.Round.Trades,
+		})
+	}
+	sort.SliceStable(standings, func(i, j int) bool {
+		return standings[i].Gold > standings[j].Gold
+	})
+	return standings
+}
+
+// IsOver returns true once a player has reached the GoldTarget, or the
+// game has reached its MaxRounds.
+func (g *Game) IsOver() bool {
+	if g.Config.MaxRounds > 0 && g.round >= g.Config.MaxRounds {
+		return true
+	}
+	if g.Config.GoldTarget > 0 {
+		for _, a := range g.accounts {
+			if a.Gold >= g.Config.GoldTarget {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// YieldRate returns the number of units of a commodity that each factory
+// produces per round.
+func (g *Game) YieldRate(c CommodityType) float64 {
+	return g.Yield[c]
+}
+
+// SendBalance informs the user of their current balance. It should be
+// called whenever their account changes.
+func (g *Game) SendBalance(user User) {
+	user.Message(NewBalanceMessage(g.Account(user)))
+}
+
+// Sell sells the user's commodities to the market, if they have them, and
+// informs everyone of the new prices.
+func (g *Game) Sell(msg SellMessage, user User) {
+	c := CommodityType(msg.Type)
+	quantity := int(msg.Quantity)
+	if !IsCommodity(c) || quantity <= 0 {
+		log.Printf("Invalid sell request from %v: %v", user.Name(), msg)
+		return
+	}
+
+	account := g.Account(user)
+	goods := map[CommodityType]int{c: quantity}
+	if !account.Debit(0, goods) {
+		return
+	}
+	account.Credit(g.market.Sell(c, quantity), nil)
+
+	g.SendBalance(user)
+	g.connection.Broadcast(NewPricesUpdatedMessage(g.market.Prices()))
+}
+
+// ActivateEffects activates one of the user's cards, provided that they hold
+// it and can pay its resource cost.
 func (g *Game) ActivateEffects(msg ActivateEffectMessage, user User) {
+	account := g.Account(user)
+	held := account.FindCard(msg.Id)
+	if held == nil {
+		user.Message(NewErrorMessage("You don't have that card."))
+		return
+	}
+	if !account.Debit(0, held.Card.ResourceCost) {
+		user.Message(NewErrorMessage("You can't afford to activate that card."))
+		return
+	}
+	account.UseCharge(msg.Id)
+	g.SendBalance(user)
+
+	// The client may ask for a shorter effect, but never a longer one.
+	rounds := EffectRounds
+	if msg.Timeout > 0 && msg.Timeout < int64(rounds) {
+		rounds = int(msg.Timeout)
+	}
+	g.effects.Add(NewEffect(held.Card, user, rounds))
+	g.Yield = g.effects.Yield()
+
 	// Inform the consumers that the effects are activated.
-	g.connection.Broadcast(NewEffectMessage(msg.Id, user.Name()))
+	g.connection.Broadcast(NewEffectMessage(msg.Id, user))
+}
+
+// ExpireEffects counts down the active effects at the end of a round, and
+// informs everyone of the ones which have expired.
+func (g *Game) ExpireEffects() {
+	expired := g.effects.EndRound()
+	if len(expired) == 0 {
+		return
+	}
+
+	g.Yield = g.effects.Yield()
+	for _, effect := range expired {
+		g.connection.Broadcast(NewEffectExpiredMessage(effect))
+	}
 }
 
 // RecieveMessage is called when a user sends a message to the server.
 func (g *Game) RecieveMessage(user User, message Message) {
+	if g.IsSpectator(user) {
+		g.recieveSpectatorMessage(user, message)
+		return
+	}
+
+	if g.state.Name() == GameOverState && (IsGameplayMessage(message) || IsHostMessage(message)) {
+		user.Message(NewErrorMessage("The game is over."))
+		return
+	}
+	if IsHostMessage(message) {
+		g.recieveHostMessage(user, message)
+		return
+	}
+	if g.paused && IsGameplayMessage(message) {
+		user.Message(NewErrorMessage("The game is paused."))
+		return
+	}
+
 	switch msg := message.(type) {
 	case JoinMessage:
-		user.Message(NewWelcomeMessage(g.name, string(g.state.Name())))
-		// TODO: store effects and broadcast to new players
+		user.Message(g.welcome(user))
+		g.SendBalance(user)
+		user.Message(NewPricesUpdatedMessage(g.market.Prices()))
+		if g.host == 0 {
+			g.setHost(user)
+		}
+	case LeaveMessage:
+		delete(g.accounts, user.Id())
+		delete(g.users, user.Id())
+		if g.host == user.Id() {
+			g.handOverHost()
+		}
 	case SetNameMessage:
 		user.SetName(msg.Name)
+	case RequestSyncMessage:
+		user.Message(g.Snapshot(user))
 	case ActivateEffectMessage:
 		g.ActivateEffects(msg, user)
+	case SellMessage:
+		// The market is closed until the game begins.
+		if g.state.Name() == WaitingState {
+			user.Message(NewErrorMessage("The market opens once the game begins."))
+		} else {
+			g.Sell(msg, user)
+		}
 	}
 	g.state.RecieveMessage(user, message)
+
+	if _, ok := message.(JoinMessage); ok {
+		// Now that the state knows about them, bring the player up to date.
+		g.sendSnapshot(user)
+	}
+}
+
+// Spectate adds a user who watches the game without playing. Spectators
+// receive broadcasts, but have no account and can't send gameplay messages.
+func (g *Game) Spectate(user User) {
+	g.spectators[user.Id()] = user
+	g.Resync(user)
+}
+
+// IsSpectator returns true if the user is watching the game.
+func (g *Game) IsSpectator(user User) bool {
+	_, ok := g.spectators[user.Id()]
+	return ok
+}
+
+// recieveSpectatorMessage handles a message sent by a spectator, which
+// never reaches the state controller.
+func (g *Game) recieveSpectatorMessage(user User, message Message) {
+	switch msg := message.(type) {
+	case LeaveMessage:
+		delete(g.spectators, user.Id())
+	case SetNameMessage:
+		user.SetName(msg.Name)
+	case RequestSyncMessage:
+		user.Message(g.Snapshot(user))
+	default:
+		if IsGameplayMessage(message) || IsHostMessage(message) {
+			user.Message(NewErrorMessage("Spectators can't play."))
+		}
+	}
+}
+
+// welcome creates the WelcomeMessage for a user.
+func (g *Game) welcome(user User) WelcomeMessage {
+	welcome := NewWelcomeMessage(g.name, string(g.state.Name()), g.effects.Active(), g.Config).(WelcomeMessage)
+	welcome.Spectator = g.IsSpectator(user)
+	welcome.HostId = g.host
+	welcome.Paused = g.paused
+	return welcome
+}
+
+// Resync sends a player who has reconnected everything they need to pick up
+// where they left off: the stage, the clock, their balance and the prices,
+// along with anything the current state keeps track of.
+func (g *Game) Resync(user User) {
+	user.Message(g.welcome(user))
+	if !g.IsSpectator(user) {
+		g.SendBalance(user)
+	}
+	user.Message(NewPricesUpdatedMessage(g.market.Prices()))
+	g.sendSnapshot(user)
+}
+
+// Snapshot describes the current stage of the game, as the user should see
+// it.
+func (g *Game) Snapshot(user User) StateSnapshotMessage {
+	remaining := time.Duration(0)
+	if g.nextTimeout > g.tick {
+		remaining = g.nextTimeout - g.tick
+	}
+	snapshot := NewStateSnapshotMessage(g.state.Name(), g.round, remaining)
+	snapshot.Paused = g.paused
+	g.state.Snapshot(user, &snapshot)
+	return snapshot
+}
+
+// sendSnapshot sends the user a StateSnapshotMessage, followed by the
+// messages which older clients use to catch up with a game in progress: the
+// time left on the clock, anything the current state keeps track of, and the
+// standings.
+func (g *Game) sendSnapshot(user User) {
+	user.Message(g.Snapshot(user))
+	if g.nextTimeout > g.tick {
+		user.Message(NewSetClockMessage(g.nextTimeout - g.tick))
+	}
+	if s, ok := g.state.(ResyncingController); ok {
+		s.Resync(user)
+	}
+	switch g.state.Name() {
+	case WaitingState, GameOverState:
+		// There are no standings before the game, and the result has them
+		// after it.
+	default:
+		user.Message(NewStandingsMessage(g.round, g.Standings()))
+	}
+}
+
+// JoinPolicy returns what should happen to a player who joins now. Anybody
+// can join a game which is waiting to begin.
+func (g *Game) JoinPolicy() JoinPolicy {
+	if g.state.Name() == WaitingState {
+		return JoinAsPlayer
+	}
+	return g.Config.JoinPolicy
 }
 
 // ChangeState can be called by the state to transition to a new state.
@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/json"
 	"math/rand"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
@@ -11,6 +12,8 @@ import (
 
 type TestConnection struct {
 	broadcastLog []string
+	finished     bool
+	kicked       []string
 }
 
 func (c *TestConnection) Broadcast(message Message) error {
@@ -23,10 +26,23 @@ func (c *TestConnection) Broadcast(message Message) error {
 }
 
 type TestUser struct {
+	id         int
 	name       string
 	messageLog []string
 }
 
+// testUserIds is the last Id given to a TestUser.
+var testUserIds int
+
+// Id assigns each TestUser a unique Id the first time it is asked for.
+func (u *TestUser) Id() int {
+	if u.id == 0 {
+		testUserIds++
+		u.id = testUserIds
+	}
+	return u.id
+}
+
 func (u *TestUser) Name() string {
 	return u.name
 }
@@ -44,6 +60,14 @@ func (u *TestUser) Message(message Message) error {
 	return nil
 }
 
+func (c *TestConnection) Finish() {
+	c.finished = true
+}
+
+func (c *TestConnection) Kick(user User) {
+	c.kicked = append(c.kicked, user.Name())
+}
+
 func CompareBroadcastLog(got, want TestConnection) string {
 	return cmp.Diff(got.broadcastLog, want.broadcastLog)
 }
@@ -54,7 +78,7 @@ func CompareMessageLog(got, want *TestUser) string {
 
 func TestChangeState(t *testing.T) {
 	connection := TestConnection{}
-	game := NewGame("g", &connection)
+	game := NewGame("g", &connection, DefaultGameConfig())
 	game.ChangeState(TradeState)
 
 	expected := TestConnection{}
@@ -71,16 +95,16 @@ func TestChangeState(t *testing.T) {
 }
 
 func TestAuctionStart(t *testing.T) {
-	// Need to set the random seed to force deterministic behavior.
-	rand.Seed(1)
 	connection := TestConnection{}
-	game := NewGame("g", &connection)
+	game := NewGame("g", &connection, DefaultGameConfig())
+	// Need to set the random seed to force deterministic behavior.
+	game.SetSeed(1)
 	game.ChangeState(AuctionState)
 
-	rand.Seed(1)
+	cards := rand.New(rand.NewSource(1))
 	expected := TestConnection{}
 	expected.Broadcast(NewGameStateChangedMessage(AuctionState))
-	expected.Broadcast(NewAuctionSeedMessage(rand.Int()))
+	expected.Broadcast(NewAuctionSeedMessage(RandomCard(cards), EnglishAuction))
 	expected.Broadcast(NewSetClockMessage(AuctionBidTime))
 
 	if diff := CompareBroadcastLog(connection, expected); diff != "" {
@@ -94,8 +118,8 @@ func TestAuctionStart(t *testing.T) {
 
 func TestReadyMechanism(t *testing.T) {
 	connection := TestConnection{}
-	game := NewGame("g", &connection)
-	game.MinPlayers = 2
+	game := NewGame("g", &connection, DefaultGameConfig())
+	game.Config.MinPlayers = 2
 
 	userA := &TestUser{}
 	userB := &TestUser{}
@@ -114,20 +138,17 @@ func TestReadyMechanism(t *testing.T) {
 
 	// Now the game should start.
 	game.RecieveMessage(userB, NewReadyMessage(true))
-	if game.state.Name() != AuctionState {
-		t.Errorf("game.state.Name() = %v, want %v", game.state.Name(), AuctionState)
+	if game.state.Name() != ProductionState {
+		t.Errorf("game.state.Name() = %v, want %v", game.state.Name(), ProductionState)
 	}
 
 }
 
-// This test requires a better implementation of unordered-equals
-// in order to verify the correct PlayerInfo output. I'm disabling
-// the test for now, until I implement a better way of comparing
-// output broadcasts.
-func DontTestPlayerInfoMessage(t *testing.T) {
+// PlayerInfo is listed in the order that players joined.
+func TestPlayerInfoMessage(t *testing.T) {
 	connection := TestConnection{}
-	game := NewGame("g", &connection)
-	game.MinPlayers = 2
+	game := NewGame("g", &connection, DefaultGameConfig())
+	game.Config.MinPlayers = 2
 
 	userA := &TestUser{name: "George"}
 	userB := &TestUser{name: "Paul"}
@@ -136,21 +157,22 @@ func DontTestPlayerInfoMessage(t *testing.T) {
 	game.RecieveMessage(userA, NewReadyMessage(false))
 
 	expected := TestConnection{}
+	expected.Broadcast(NewHostChangedMessage(userB))
 
 	info := []PlayerInfo{
-		{Name: "Paul", Ready: false},
+		{Id: userB.Id(), Name: "Paul", Ready: false},
 	}
 	expected.Broadcast(NewPlayerInfoUpdateMessage(info))
 
 	info = []PlayerInfo{
-		{Name: "Paul", Ready: false},
-		{Name: "George", Ready: true},
+		{Id: userB.Id(), Name: "Paul", Ready: false},
+		{Id: userA.Id(), Name: "George", Ready: true},
 	}
 	expected.Broadcast(NewPlayerInfoUpdateMessage(info))
 
 	info = []PlayerInfo{
-		{Name: "Paul", Ready: false},
-		{Name: "George", Ready: false},
+		{Id: userB.Id(), Name: "Paul", Ready: false},
+		{Id: userA.Id(), Name: "George", Ready: false},
 	}
 	expected.Broadcast(NewPlayerInfoUpdateMessage(info))
 
@@ -161,8 +183,8 @@ func DontTestPlayerInfoMessage(t *testing.T) {
 }
 func TestReadyMechanismWithMorePlayers(t *testing.T) {
 	connection := TestConnection{}
-	game := NewGame("g", &connection)
-	game.MinPlayers = 2
+	game := NewGame("g", &connection, DefaultGameConfig())
+	game.Config.MinPlayers = 2
 
 	userA := &TestUser{}
 	userB := &TestUser{}
@@ -177,15 +199,15 @@ func TestReadyMechanismWithMorePlayers(t *testing.T) {
 	}
 
 	game.RecieveMessage(userB, NewReadyMessage(true))
-	if game.state.Name() != AuctionState {
-		t.Errorf("game.state.Name() = %v, want %v", game.state.Name(), AuctionState)
+	if game.state.Name() != ProductionState {
+		t.Errorf("game.state.Name() = %v, want %v", game.state.Name(), ProductionState)
 	}
 }
 
 func TestReadyMechanismWithLeaver(t *testing.T) {
 	connection := TestConnection{}
-	game := NewGame("g", &connection)
-	game.MinPlayers = 2
+	game := NewGame("g", &connection, DefaultGameConfig())
+	game.Config.MinPlayers = 2
 
 	userA := &TestUser{}
 	userB := &TestUser{}
@@ -201,16 +223,16 @@ func TestReadyMechanismWithLeaver(t *testing.T) {
 
 	// Now the user has left, and the rest are ready, so begin.
 	game.RecieveMessage(userB, NewLeaveMessage())
-	if game.state.Name() != AuctionState {
-		t.Errorf("game.state.Name() = %v, want %v", game.state.Name(), AuctionState)
+	if game.state.Name() != ProductionState {
+		t.Errorf("game.state.Name() = %v, want %v", game.state.Name(), ProductionState)
 	}
 }
 
 func TestAuctionPhases(t *testing.T) {
-	// Need to set the random seed to force deterministic behavior.
-	rand.Seed(1)
 	connection := TestConnection{}
-	game := NewGame("g", &connection)
+	game := NewGame("g", &connection, DefaultGameConfig())
+	// Need to set the random seed to force deterministic behavior.
+	game.SetSeed(1)
 	game.ChangeState(AuctionState)
 
 	// Bid on a card.
@@ -226,18 +248,18 @@ func TestAuctionPhases(t *testing.T) {
 	// Wait until the third auction expires with no bids.
 	game.Tick(3*AuctionBidTime + 3)
 
-	rand.Seed(1)
+	cards := rand.New(rand.NewSource(1))
 	expected := TestConnection{}
 	expected.Broadcast(NewGameStateChangedMessage(AuctionState))
-	expected.Broadcast(NewAuctionSeedMessage(rand.Int()))
+	expected.Broadcast(NewAuctionSeedMessage(RandomCard(cards), EnglishAuction))
 	expected.Broadcast(NewSetClockMessage(AuctionBidTime))
 
-	expected.Broadcast(NewBidUpdatedMessage(10, user.Name()))
+	expected.Broadcast(NewBidUpdatedMessage(10, user))
 	expected.Broadcast(NewSetClockMessage(AuctionBidTime))
-	expected.Broadcast(NewAuctionSeedMessage(rand.Int()))
+	expected.Broadcast(NewAuctionSeedMessage(RandomCard(cards), EnglishAuction))
 	expected.Broadcast(NewSetClockMessage(AuctionBidTime))
 
-	expected.Broadcast(NewAuctionSeedMessage(rand.Int()))
+	expected.Broadcast(NewAuctionSeedMessage(RandomCard(cards), EnglishAuction))
 	expected.Broadcast(NewSetClockMessage(AuctionBidTime))
 
 	expected.Broadcast(NewGameStateChangedMessage(TradeState))
@@ -250,17 +272,20 @@ func TestAuctionPhases(t *testing.T) {
 
 func TestEffectsBroadcast(t *testing.T) {
 	connection := TestConnection{}
-	game := NewGame("g", &connection)
-	game.MinPlayers = 2
+	game := NewGame("g", &connection, DefaultGameConfig())
+	game.Config.MinPlayers = 2
 
 	userA := &TestUser{name: "Faker"}
+	game.Account(userA).AddCard(AllCards[1])
+	game.Account(userA).AddCard(AllCards[2])
+	game.Account(userA).Credit(0, map[CommodityType]int{Tomato: 10})
 
 	game.RecieveMessage(userA, NewActivateEffectMessage(1, 100))
 	game.RecieveMessage(userA, NewActivateEffectMessage(2, 100))
 
 	expected := TestConnection{}
-	expected.Broadcast(NewEffectMessage(1, "Faker"))
-	expected.Broadcast(NewEffectMessage(2, "Faker"))
+	expected.Broadcast(NewEffectMessage(1, userA))
+	expected.Broadcast(NewEffectMessage(2, userA))
 
 	if diff := CompareBroadcastLog(connection, expected); diff != "" {
 		t.Errorf("Got: %v", connection.broadcastLog)
@@ -268,3 +293,347 @@ func TestEffectsBroadcast(t *testing.T) {
 		t.Errorf("Auction bidding: %v", diff)
 	}
 }
+
+func TestSell(t *testing.T) {
+	connection := TestConnection{}
+	game := NewGame("g", &connection, DefaultGameConfig())
+
+	user := &TestUser{name: "seller"}
+	game.Account(user).Credit(0, map[CommodityType]int{Corn: 2})
+
+	// The market is closed while waiting for players.
+	game.RecieveMessage(user, NewSellMessage(1, Corn))
+	if n := game.Account(user).Inventory[Corn]; n != 2 {
+		t.Errorf("Sold corn while waiting, have %v left", n)
+	}
+	want := &TestUser{}
+	want.Message(NewErrorMessage("The market opens once the game begins."))
+	if diff := CompareMessageLog(user, want); diff != "" {
+		t.Errorf("Sell while waiting: %v", diff)
+	}
+
+	game.ChangeState(TradeState)
+	connection.broadcastLog = nil
+	user.messageLog = nil
+
+	// Can't sell more than you have, or things that don't exist.
+	game.RecieveMessage(user, NewSellMessage(3, Corn))
+	game.RecieveMessage(user, NewSellMessage(1, "gold"))
+	game.RecieveMessage(user, NewSellMessage(-1, Corn))
+
+	game.RecieveMessage(user, NewSellMessage(2, Corn))
+
+	market := NewMarket()
+	account := NewAccount()
+	account.Credit(market.Sell(Corn, 2), nil)
+
+	want = &TestUser{}
+	want.Message(NewBalanceMessage(account))
+	if diff := CompareMessageLog(user, want); diff != "" {
+		t.Errorf("Sell balance: %v", diff)
+	}
+
+	expected := TestConnection{}
+	expected.Broadcast(NewPricesUpdatedMessage(market.Prices()))
+	if diff := CompareBroadcastLog(connection, expected); diff != "" {
+		t.Errorf("Sell prices: %v", diff)
+	}
+}
+
+func TestGameOver(t *testing.T) {
+	connection := TestConnection{}
+	game := NewGame("g", &connection, DefaultGameConfig())
+	game.Config.GoldTarget = 50
+
+	winner := &TestUser{name: "winner"}
+	loser := &TestUser{name: "loser"}
+	game.ChangeState(SummaryState)
+	game.Account(loser).Credit(10, nil)
+
+	// Nobody has enough gold yet, so the next round begins.
+	game.Tick(2 * SummaryStageTime)
+	if game.state.Name() != ProductionState {
+		t.Fatalf("game.state.Name() = %v, want %v", game.state.Name(), ProductionState)
+	}
+
+	game.Account(winner).Credit(25, nil)
+	game.ChangeState(SummaryState)
+	connection.broadcastLog = nil
+	game.Tick(4 * SummaryStageTime)
+	if game.state.Name() != GameOverState {
+		t.Fatalf("game.state.Name() = %v, want %v", game.state.Name(), GameOverState)
+	}
+
+	expected := TestConnection{}
+	expected.Broadcast(NewGameStateChangedMessage(GameOverState))
+	expected.Broadcast(NewGameOverMessage("winner", []Standing{
+		{Id: winner.Id(), Name: "winner", Gold: 50, GoldEarned: 25},
+		{Id: loser.Id(), Name: "loser", Gold: 35, Production: 4},
+	}))
+	if diff := CompareBroadcastLog(connection, expected); diff != "" {
+		t.Errorf("GameOver: %v", diff)
+	}
+	if !connection.finished {
+		t.Errorf("Expected the connection to be finished")
+	}
+
+	// Gameplay is no longer possible.
+	winner.messageLog = nil
+	game.RecieveMessage(winner, NewSellMessage(1, Corn))
+	want := &TestUser{}
+	want.Message(NewErrorMessage("The game is over."))
+	if diff := CompareMessageLog(winner, want); diff != "" {
+		t.Errorf("Gameplay after game over: %v", diff)
+	}
+}
+
+func TestRoundLimit(t *testing.T) {
+	connection := TestConnection{}
+	game := NewGame("g", &connection, DefaultGameConfig())
+	game.Config.MaxRounds = 2
+
+	game.ChangeState(ProductionState)
+	if game.IsOver() {
+		t.Errorf("game.IsOver() = true after one round")
+	}
+	game.ChangeState(ProductionState)
+	if !game.IsOver() {
+		t.Errorf("game.IsOver() = false after %v rounds", game.Config.MaxRounds)
+	}
+}
+
+func TestActivateEffectValidation(t *testing.T) {
+	connection := TestConnection{}
+	game := NewGame("g", &connection, DefaultGameConfig())
+
+	user := &TestUser{name: "player"}
+	game.Account(user).AddCard(AllCards[1])
+
+	// The user doesn't hold card 2 at all.
+	game.RecieveMessage(user, NewActivateEffectMessage(2, 100))
+	// The user can't pay the resource cost of card 1.
+	game.RecieveMessage(user, NewActivateEffectMessage(1, 100))
+
+	want := &TestUser{}
+	want.Message(NewErrorMessage("You don't have that card."))
+	want.Message(NewErrorMessage("You can't afford to activate that card."))
+	if diff := CompareMessageLog(user, want); diff != "" {
+		t.Errorf("Refused activation: %v", diff)
+	}
+	if len(connection.broadcastLog) != 0 {
+		t.Errorf("Expected no broadcasts, got %q", connection.broadcastLog)
+	}
+
+	// Once they can pay, the card is activated and its charge is used up.
+	game.Account(user).Credit(0, map[CommodityType]int{Tomato: 5})
+	game.RecieveMessage(user, NewActivateEffectMessage(1, 100))
+
+	account := game.Account(user)
+	if account.Inventory[Tomato] != 0 || len(account.Cards) != 0 {
+		t.Errorf("Unexpected account after activation: %+v", account)
+	}
+
+	// The card can't be activated twice.
+	game.RecieveMessage(user, NewActivateEffectMessage(1, 100))
+	if len(connection.broadcastLog) != 1 {
+		t.Errorf("Expected a single effect, got %q", connection.broadcastLog)
+	}
+}
+
+func TestEffectsExpire(t *testing.T) {
+	connection := TestConnection{}
+	game := NewGame("g", &connection, DefaultGameConfig())
+
+	user := &TestUser{name: "Faker"}
+	game.Account(user).AddCard(AllCards[3])
+	game.Account(user).Credit(0, map[CommodityType]int{Tomato: 5})
+	game.RecieveMessage(user, NewActivateEffectMessage(3, 0))
+
+	if game.Yield[Corn] != 0.8 {
+		t.Errorf("game.Yield[Corn] = %v, want 0.8", game.Yield[Corn])
+	}
+
+	// Players who join later are told about the effect.
+	late := &TestUser{name: "Late"}
+	game.RecieveMessage(late, NewJoinMessage())
+	want := &TestUser{}
+	want.Message(NewWelcomeMessage("g", string(WaitingState), []Effect{
+		NewEffect(AllCards[3], user, EffectRounds),
+	}, game.Config))
+	if diff := cmp.Diff(late.messageLog[0], want.messageLog[0]); diff != "" {
+		t.Errorf("Welcome: %v", diff)
+	}
+
+	// The effect wears off after enough rounds.
+	for i := 0; i < EffectRounds; i++ {
+		game.ChangeState(ProductionState)
+	}
+	if game.Yield[Corn] != BaseYield {
+		t.Errorf("game.Yield[Corn] = %v, want %v", game.Yield[Corn], BaseYield)
+	}
+
+	expired, _ := json.Marshal(NewEffectExpiredMessage(NewEffect(AllCards[3], user, 0)))
+	found := false
+	for _, m := range connection.broadcastLog {
+		if m == string(expired) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected %s to be broadcast, got %q", expired, connection.broadcastLog)
+	}
+}
+
+func TestResync(t *testing.T) {
+	game, ctrl := newTestAuction(EnglishAuction)
+	game.Tick(time.Second)
+
+	u1 := &TestUser{name: "u1"}
+	u2 := &TestUser{name: "u2"}
+	game.RecieveMessage(u1, NewBidMessage(10))
+	game.RecieveMessage(u2, NewJoinMessage())
+
+	u2.messageLog = nil
+	game.Resync(u2)
+
+	welcome := NewWelcomeMessage("g", string(AuctionState), []Effect{}, game.Config).(WelcomeMessage)
+	welcome.HostId = u2.Id()
+	want := &TestUser{}
+	want.Message(welcome)
+	want.Message(NewBalanceMessage(game.Account(u2)))
+	want.Message(NewPricesUpdatedMessage(game.market.Prices()))
+	want.Message(game.Snapshot(u2))
+	want.Message(NewSetClockMessage(AuctionBidTime))
+	want.Message(NewAuctionSeedMessage(ctrl.card, EnglishAuction))
+	want.Message(NewBidUpdatedMessage(10, u1))
+	want.Message(NewStandingsMessage(0, game.Standings()))
+	if diff := CompareMessageLog(u2, want); diff != "" {
+		t.Errorf("Resync: %v", diff)
+	}
+}
+
+func TestJoinInProgress(t *testing.T) {
+	game, ctrl := newTestAuction(EnglishAuction)
+	game.Tick(time.Second)
+	game.RecieveMessage(&TestUser{name: "u1"}, NewBidMessage(10))
+
+	// Somebody who joins part way through is brought up to date.
+	late := &TestUser{name: "late"}
+	game.RecieveMessage(late, NewJoinMessage())
+
+	welcome := NewWelcomeMessage("g", string(AuctionState), []Effect{}, game.Config).(WelcomeMessage)
+	want := &TestUser{}
+	want.Message(welcome)
+	want.Message(NewBalanceMessage(game.Account(late)))
+	want.Message(NewPricesUpdatedMessage(game.market.Prices()))
+	want.Message(game.Snapshot(late))
+	want.Message(NewSetClockMessage(AuctionBidTime))
+	want.Message(NewAuctionSeedMessage(ctrl.card, EnglishAuction))
+	want.Message(NewBidUpdatedMessage(10, ctrl.winner))
+	want.Message(NewStandingsMessage(0, game.Standings()))
+	if diff := CompareMessageLog(late, want); diff != "" {
+		t.Errorf("Join in progress: %v", diff)
+	}
+}
+
+func TestStateSnapshot(t *testing.T) {
+	connection := TestConnection{}
+	game := NewGame("g", &connection, DefaultGameConfig())
+	u1 := &TestUser{name: "u1"}
+	u2 := &TestUser{name: "u2"}
+	game.RecieveMessage(u1, NewJoinMessage())
+	game.RecieveMessage(u2, NewJoinMessage())
+	game.RecieveMessage(u1, NewReadyMessage(true))
+
+	// While waiting, the snapshot shows who is ready.
+	u2.messageLog = nil
+	game.RecieveMessage(u2, NewRequestSyncMessage())
+	want := &TestUser{}
+	snapshot := NewStateSnapshotMessage(WaitingState, 0, 0)
+	snapshot.Players = []PlayerInfo{{u1.Id(), "u1", true}, {u2.Id(), "u2", false}}
+	want.Message(snapshot)
+	if diff := CompareMessageLog(u2, want); diff != "" {
+		t.Errorf("Waiting snapshot: %v", diff)
+	}
+
+	// During the auction, it shows the card and the bidding.
+	game, ctrl := newTestAuction(EnglishAuction)
+	game.Tick(time.Second)
+	game.RecieveMessage(u1, NewJoinMessage())
+	game.RecieveMessage(u1, NewBidMessage(10))
+	snapshot = NewStateSnapshotMessage(AuctionState, 0, AuctionBidTime)
+	snapshot.Card = &ctrl.card
+	snapshot.Format = EnglishAuction
+	snapshot.Bid = 10
+	snapshot.Winner = "u1"
+	snapshot.WinnerId = u1.Id()
+	if diff := cmp.Diff(snapshot, game.Snapshot(u2)); diff != "" {
+		t.Errorf("Auction snapshot: %v", diff)
+	}
+
+	// Spectators can ask for one too.
+	tv := &TestUser{name: "tv"}
+	game.Spectate(tv)
+	tv.messageLog = nil
+	game.RecieveMessage(tv, NewRequestSyncMessage())
+	want = &TestUser{}
+	want.Message(snapshot)
+	if diff := CompareMessageLog(tv, want); diff != "" {
+		t.Errorf("Spectator snapshot: %v", diff)
+	}
+
+	// While trading, it shows the player's pending offers, including the
+	// newest one.
+	game, trade, alice, bob := newTestTrade()
+	trade.RecieveMessage(alice, NewTradeOfferMessage("bob", Goods{}, Goods{}))
+	trade.RecieveMessage(bob, NewTradeOfferMessage("alice", Goods{}, Goods{}))
+	snapshot = NewStateSnapshotMessage(TradeState, 0, 0)
+	snapshot.Offers = []TradeOffer{
+		{Id: 1, From: "alice", FromId: alice.Id(), To: "bob", ToId: bob.Id()},
+		{Id: 2, From: "bob", FromId: bob.Id(), To: "alice", ToId: alice.Id()},
+	}
+	if diff := cmp.Diff(snapshot, game.Snapshot(bob)); diff != "" {
+		t.Errorf("Trade snapshot: %v", diff)
+	}
+}
+
+func TestSpectator(t *testing.T) {
+	connection := TestConnection{}
+	game := NewGame("g", &connection, DefaultGameConfig())
+	game.Config.MinPlayers = 1
+
+	tv := &TestUser{name: "tv"}
+	game.Spectate(tv)
+
+	welcome := NewWelcomeMessage("g", string(WaitingState), []Effect{}, game.Config).(WelcomeMessage)
+	welcome.Spectator = true
+	want := &TestUser{}
+	want.Message(welcome)
+	want.Message(NewPricesUpdatedMessage(game.market.Prices()))
+	want.Message(game.Snapshot(tv))
+	if diff := CompareMessageLog(tv, want); diff != "" {
+		t.Errorf("Spectate: %v", diff)
+	}
+
+	// Spectators can't play, and don't hold up the game.
+	tv.messageLog = nil
+	game.RecieveMessage(tv, NewReadyMessage(true))
+	want = &TestUser{}
+	want.Message(NewErrorMessage("Spectators can't play."))
+	if diff := CompareMessageLog(tv, want); diff != "" {
+		t.Errorf("Spectator ready: %v", diff)
+	}
+	if game.state.Name() != WaitingState {
+		t.Errorf("game.state.Name() = %v, want %v", game.state.Name(), WaitingState)
+	}
+	if len(game.Users()) != 0 {
+		t.Errorf("Expected spectators not to have accounts")
+	}
+
+	player := &TestUser{name: "player"}
+	game.RecieveMessage(player, NewJoinMessage())
+	game.RecieveMessage(player, NewReadyMessage(true))
+	if game.state.Name() != ProductionState {
+		t.Errorf("game.state.Name() = %v, want %v", game.state.Name(), ProductionState)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// The host is the player who manages the game. The first player to join
+// becomes the host, and when they leave, the player who has been in the game
+// the longest takes over.
+
+// Host returns the host of the game, or nil if there isn't one.
+func (g *Game) Host() User {
+	return g.UserById(g.host)
+}
+
+// setHost makes the user the host, and tells everyone.
+func (g *Game) setHost(user User) {
+	g.host = 0
+	if user != nil {
+		g.host = user.Id()
+	}
+	g.connection.Broadcast(NewHostChangedMessage(user))
+}
+
+// handOverHost passes the role of host to the player with the lowest Id.
+func (g *Game) handOverHost() {
+	var next User
+	for id, u := range g.users {
+		if next == nil || id < next.Id() {
+			next = u
+		}
+	}
+	g.setHost(next)
+}
+
+// recieveHostMessage carries out an action which only the host may take.
+func (g *Game) recieveHostMessage(user User, message Message) {
+	if user.Id() != g.host {
+		user.Message(NewErrorMessage("Only the host can do that."))
+		return
+	}
+
+	switch msg := message.(type) {
+	case KickMessage:
+		g.Kick(user, msg.PlayerId)
+	case PauseMessage:
+		g.Pause(user)
+	case ResumeMessage:
+		g.Resume(user)
+	case SkipPhaseMessage:
+		g.SkipPhase()
+	case RestartMessage:
+		g.Restart()
+	case ConfigureMessage:
+		g.Configure(user, msg)
+	}
+}
+
+// Configure changes the settings of the game, before it begins.
+func (g *Game) Configure(host User, msg ConfigureMessage) {
+	if g.state.Name() != WaitingState {
+		host.Message(NewErrorMessage("The game has already begun."))
+		return
+	}
+	config := g.Config
+	if err := json.Unmarshal(msg.Config, &config); err != nil {
+		host.Message(NewErrorMessage("Unable to decode config."))
+		return
+	}
+	if err := config.Validate(); err != nil {
+		host.Message(NewErrorMessage(err.Error()))
+		return
+	}
+	g.Config = config
+	g.connection.Broadcast(NewConfigChangedMessage(config))
+
+	// The minimum number of players may have dropped.
+	if w, ok := g.state.(*WaitingController); ok {
+		w.proceedIfReady()
+	}
+}
+
+// Kick removes a player or spectator from the game.
+func (g *Game) Kick(host User, id int) {
+	target := g.UserById(id)
+	if target == nil {
+		target = g.spectators[id]
+	}
+	if target == nil {
+		host.Message(NewErrorMessage("That player isn't in the game."))
+		return
+	}
+	if target == host {
+		host.Message(NewErrorMessage("You can't kick yourself."))
+		return
+	}
+
+	log.Printf("Host %q kicked %q from game %q", host.Name(), target.Name(), g.name)
+	g.connection.Broadcast(NewPlayerKickedMessage(target))
+	g.RecieveMessage(target, NewLeaveMessage())
+	g.connection.Kick(target)
+}
+
+// Pause freezes the game clock, so that no timers run out, and stops
+// players from making moves.
+func (g *Game) Pause(host User) {
+	if g.paused {
+		host.Message(NewErrorMessage("The game is already paused."))
+		return
+	}
+	g.paused = true
+	g.connection.Broadcast(NewGamePausedMessage(true))
+}
+
+// Resume restarts the game clock from where it was paused.
+func (g *Game) Resume(host User) {
+	if !g.paused {
+		host.Message(NewErrorMessage("The game isn't paused."))
+		return
+	}
+	g.paused = false
+	g.connection.Broadcast(NewGamePausedMessage(false))
+	if g.nextTimeout > g.tick {
+		g.connection.Broadcast(NewSetClockMessage(g.nextTimeout - g.tick))
+	}
+}
+
+// SkipPhase ends the current state early, and moves on to the next one.
+func (g *Game) SkipPhase() {
+	from := g.state.Name()
+	g.connection.Broadcast(NewPhaseSkippedMessage(from))
+	g.ChangeState(g.nextState())
+}
+
+// nextState returns the state which follows the current one.
+func (g *Game) nextState() GameState {
+	switch g.state.Name() {
+	case WaitingState:
+		return ProductionState
+	case ProductionState:
+		return AuctionState
+	case AuctionState:
+		return TradeState
+	case TradeState:
+		return SummaryState
+	case SummaryState:
+		if g.IsOver() {
+			return GameOverState
+		}
+		return ProductionState
+	}
+	return GameOverState
+}
+
+// Restart returns the game to the WaitingState, and gives every player a
+// fresh start.
+func (g *Game) Restart() {
+	g.connection.Broadcast(NewGameRestartedMessage())
+	g.paused = false
+	g.ChangeState(WaitingState)
+
+	g.round = 0
+	g.market = NewMarket()
+	g.effects = Effects{}
+	g.Yield = g.effects.Yield()
+	for id, u := range g.users {
+		g.accounts[id] = NewAccount()
+		g.SendBalance(u)
+	}
+	g.connection.Broadcast(NewPricesUpdatedMessage(g.market.Prices()))
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestHostGame() (*Game, *TestConnection, *TestUser, *TestUser) {
+	connection := &TestConnection{}
+	game := NewGame("g", connection, DefaultGameConfig())
+	host := &TestUser{name: "host"}
+	guest := &TestUser{name: "guest"}
+	game.RecieveMessage(host, NewJoinMessage())
+	game.RecieveMessage(guest, NewJoinMessage())
+	return game, connection, host, guest
+}
+
+func TestHostHandover(t *testing.T) {
+	game, connection, host, guest := newTestHostGame()
+	if game.Host() != host {
+		t.Fatalf("Expected the first player to be host")
+	}
+
+	connection.broadcastLog = nil
+	game.RecieveMessage(host, NewLeaveMessage())
+	if game.Host() != guest {
+		t.Errorf("Expected the host role to pass to the guest")
+	}
+
+	expected := TestConnection{}
+	expected.Broadcast(NewHostChangedMessage(guest))
+	expected.Broadcast(NewPlayerInfoUpdateMessage([]PlayerInfo{
+		{Id: guest.Id(), Name: "guest"},
+	}))
+	if diff := CompareBroadcastLog(*connection, expected); diff != "" {
+		t.Errorf("Handover: %v", diff)
+	}
+}
+
+func TestHostAuthorization(t *testing.T) {
+	game, _, host, guest := newTestHostGame()
+
+	guest.messageLog = nil
+	game.RecieveMessage(guest, NewKickMessage(host.Id()))
+	want := &TestUser{}
+	want.Message(NewErrorMessage("Only the host can do that."))
+	if diff := CompareMessageLog(guest, want); diff != "" {
+		t.Errorf("Guest kick: %v", diff)
+	}
+	if game.Host() != host || game.UserById(host.Id()) == nil {
+		t.Errorf("Expected the guest's kick to be refused")
+	}
+}
+
+func TestHostKick(t *testing.T) {
+	game, connection, host, guest := newTestHostGame()
+
+	connection.broadcastLog = nil
+	game.RecieveMessage(host, NewKickMessage(guest.Id()))
+	if game.UserById(guest.Id()) != nil {
+		t.Errorf("Expected the guest to be removed")
+	}
+	if len(connection.kicked) != 1 || connection.kicked[0] != "guest" {
+		t.Errorf("Expected the guest to be disconnected, got %v", connection.kicked)
+	}
+	expected := TestConnection{}
+	expected.Broadcast(NewPlayerKickedMessage(guest))
+	if len(connection.broadcastLog) == 0 || connection.broadcastLog[0] != expected.broadcastLog[0] {
+		t.Errorf("Expected the kick to be announced, got %v", connection.broadcastLog)
+	}
+}
+
+func TestHostPause(t *testing.T) {
+	game, connection, host, guest := newTestHostGame()
+	game.Tick(time.Second)
+	game.ChangeState(ProductionState)
+
+	connection.broadcastLog = nil
+	game.RecieveMessage(host, NewPauseMessage())
+
+	// The clock is frozen while the game is paused.
+	game.Tick(time.Second + 2*ProductionTimeout)
+	if game.state.Name() != ProductionState {
+		t.Errorf("game.state.Name() = %v, want %v", game.state.Name(), ProductionState)
+	}
+	guest.messageLog = nil
+	game.RecieveMessage(guest, NewSellMessage(1, Corn))
+	want := &TestUser{}
+	want.Message(NewErrorMessage("The game is paused."))
+	if diff := CompareMessageLog(guest, want); diff != "" {
+		t.Errorf("Sell while paused: %v", diff)
+	}
+
+	// Once resumed, the clock picks up where it left off.
+	game.RecieveMessage(host, NewResumeMessage())
+	expected := TestConnection{}
+	expected.Broadcast(NewGamePausedMessage(true))
+	expected.Broadcast(NewGamePausedMessage(false))
+	expected.Broadcast(NewSetClockMessage(ProductionTimeout))
+	if diff := CompareBroadcastLog(*connection, expected); diff != "" {
+		t.Errorf("Pause and resume: %v", diff)
+	}
+
+	game.Tick(time.Second + 4*ProductionTimeout)
+	if game.state.Name() != AuctionState {
+		t.Errorf("game.state.Name() = %v, want %v", game.state.Name(), AuctionState)
+	}
+}
+
+func TestHostSkipAndRestart(t *testing.T) {
+	game, _, host, guest := newTestHostGame()
+
+	game.RecieveMessage(host, NewSkipPhaseMessage())
+	if game.state.Name() != ProductionState {
+		t.Errorf("game.state.Name() = %v, want %v", game.state.Name(), ProductionState)
+	}
+	game.RecieveMessage(host, NewSkipPhaseMessage())
+	if game.state.Name() != AuctionState {
+		t.Errorf("game.state.Name() = %v, want %v", game.state.Name(), AuctionState)
+	}
+
+	game.Account(guest).Credit(100, nil)
+	game.RecieveMessage(host, NewRestartMessage())
+	if game.state.Name() != WaitingState {
+		t.Errorf("game.state.Name() = %v, want %v", game.state.Name(), WaitingState)
+	}
+	if gold := game.Account(guest).Gold; gold != StartingGold {
+		t.Errorf("guest has %v gold, want %v", gold, StartingGold)
+	}
+	if game.round != 0 {
+		t.Errorf("game.round = %v, want 0", game.round)
+	}
+
+	// Everyone has to be ready again before the game starts.
+	game.RecieveMessage(host, NewReadyMessage(true))
+	if game.state.Name() != WaitingState {
+		t.Errorf("game.state.Name() = %v, want %v", game.state.Name(), WaitingState)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// StartingGold is the amount of gold each player begins the game with.
+	StartingGold = 25
+	// StartingFactories is the number of factories of each commodity that
+	// a player owns when they join.
+	StartingFactories = 1
+)
+
+// Account is the server's record of a single player's resources. Clients
+// only display what they are told, so every change to a player's gold,
+// inventory or factories must happen here.
+type Account struct {
+	Gold      int
+	Inventory map[CommodityType]int
+	Factories map[CommodityType]int
+	Cards     []HeldCard
+	Round     RoundStats
+}
+
+// HeldCard is a card in a player's hand, along with the number of times
+// that it can still be activated.
+type HeldCard struct {
+	Card    Card `json:"card"`
+	Charges int  `json:"charges"`
+}
+
+// RoundStats records what a player achieved during the current round.
+type RoundStats struct {
+	StartingGold int
+	Production   int
+	AuctionsWon  int
+	Trades       int
+}
+
+// NewAccount opens an account with the starting gold and factories.
+func NewAccount() *Account {
+	a := Account{
+		Gold:      StartingGold,
+		Inventory: make(map[CommodityType]int),
+		Factories: make(map[CommodityType]int),
+		Round:     RoundStats{StartingGold: StartingGold},
+	}
+	for _, c := range AllCommodities {
+		a.Inventory[c] = 0
+		a.Factories[c] = StartingFactories
+	}
+	return &a
+}
+
+// CanAfford returns true if the account holds at least the given amount of
+// gold and commodities.
+func (a *Account) CanAfford(gold int, materials map[CommodityType]int) bool {
+	if gold < 0 || a.Gold < gold {
+		return false
+	}
+	for c, n := range materials {
+		if n < 0 || a.Inventory[c] < n {
+			return false
+		}
+	}
+	return true
+}
+
+// Debit removes gold and commodities from the account. If the account can't
+// afford it, nothing is removed and false is returned.
+func (a *Account) Debit(gold int, materials map[CommodityType]int) bool {
+	if !a.CanAfford(gold, materials) {
+		return false
+	}
+	a.Gold -= gold
+	for c, n := range materials {
+		a.Inventory[c] -= n
+	}
+	return true
+}
+
+// Credit adds gold and commodities to the account.
+func (a *Account) Credit(gold int, materials map[CommodityType]int) {
+	a.Gold += gold
+	for c, n := range materials {
+		a.Inventory[c] += n
+	}
+}
+
+// AddCard adds a card to the player's hand, with all of its charges.
+func (a *Account) AddCard(card Card) {
+	a.Cards = append(a.Cards, HeldCard{Card: card, Charges: card.Charges})
+}
+
+// FindCard returns the held card with the given id, or nil if the player
+// doesn't hold it.
+func (a *Account) FindCard(id int) *HeldCard {
+	for i := range a.Cards {
+		if a.Cards[i].Card.Id == id {
+			return &a.Cards[i]
+		}
+	}
+	return nil
+}
+
+// UseCharge uses up one charge of the held card with the given id. Cards
+// with no charges left are removed from the hand.
+func (a *Account) UseCharge(id int) {
+	for i := range a.Cards {
+		if a.Cards[i].Card.Id != id {
+			continue
+		}
+		if a.Cards[i].Charges == InfiniteCharges {
+			return
+		}
+		a.Cards[i].Charges--
+		if a.Cards[i].Charges <= 0 {
+			a.Cards = append(a.Cards[:i], a.Cards[i+1:]...)
+		}
+		return
+	}
+}
+
+// BeginRound resets the round statistics.
+func (a *Account) BeginRound() {
+	a.Round = RoundStats{StartingGold: a.Gold}
+}
+
+// copyMaterials returns a copy of a material map, so that it can be sent
+// to a client without sharing the account's storage.
+func copyMaterials(materials map[CommodityType]int) map[CommodityType]int {
+	result := make(map[CommodityType]int, len(materials))
+	for c, n := range materials {
+		result[c] = n
+	}
+	return result
+}
+
+// Materials is an amount of each commodity, as sent between the client and
+// the server.
+type Materials map[CommodityType]int
+
+// UnmarshalJSON decodes materials from a JSON object, and checks that they
+// are valid. Older clients encode the object as a JSON string, so that is
+// accepted too. Null is the same as leaving the materials out.
+func (m *Materials) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*m = nil
+		return nil
+	}
+	var legacy string
+	if err := json.Unmarshal(data, &legacy); err == nil {
+		data = []byte(legacy)
+	}
+	materials, err := ParseMaterials(string(data))
+	if err != nil {
+		return err
+	}
+	*m = materials
+	return nil
+}
+
+// AllMaterials returns a copy of the materials which includes every
+// commodity, even if there are none of it.
+func AllMaterials(materials Materials) Materials {
+	result := make(Materials)
+	for _, c := range AllCommodities {
+		result[c] = materials[c]
+	}
+	return result
+}
+
+// IsCommodity returns true if c is one of AllCommodities.
+func IsCommodity(c CommodityType) bool {
+	for _, x := range AllCommodities {
+		if x == c {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseMaterials decodes a JSON encoded material map, such as the one
+// sent by the client when trading, and checks that it only contains known
+// commodities in non-negative amounts.
+func ParseMaterials(data string) (Materials, error) {
+	materials := make(map[CommodityType]int)
+	if err := json.Unmarshal([]byte(data), &materials); err != nil {
+		return nil, fmt.Errorf("Unable to decode materials: %q", data)
+	}
+	for c, n := range materials {
+		if !IsCommodity(c) {
+			return nil, fmt.Errorf("Unknown commodity: %q", c)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("Negative amount of %v: %v", c, n)
+		}
+	}
+	return materials, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+func TestAccountDebit(t *testing.T) {
+	a := NewAccount()
+	a.Credit(0, map[CommodityType]int{Corn: 2})
+
+	if a.Debit(StartingGold+1, nil) {
+		t.Errorf("Debit(%v) succeeded, expected failure", StartingGold+1)
+	}
+	if a.Debit(5, map[CommodityType]int{Corn: 3}) {
+		t.Errorf("Debit(3 corn) succeeded with only 2 corn")
+	}
+	if a.Gold != StartingGold || a.Inventory[Corn] != 2 {
+		t.Errorf("Failed debit changed account: %+v", a)
+	}
+
+	if !a.Debit(5, map[CommodityType]int{Corn: 2}) {
+		t.Errorf("Debit(5, 2 corn) failed, expected success")
+	}
+	if a.Gold != StartingGold-5 || a.Inventory[Corn] != 0 {
+		t.Errorf("Unexpected account after debit: %+v", a)
+	}
+
+	if a.Debit(-1, nil) {
+		t.Errorf("Debit(-1) succeeded, expected failure")
+	}
+}
+
+func TestParseMaterials(t *testing.T) {
+	m, err := ParseMaterials(`{"corn": 2, "tomato": 1}`)
+	if err != nil {
+		t.Fatalf("ParseMaterials(...) returned err: %v", err)
+	}
+	if m[Corn] != 2 || m[Tomato] != 1 {
+		t.Errorf("ParseMaterials(...) = %v", m)
+	}
+
+	invalid := []string{
+		`a gold bar`,
+		`{"gold": 2}`,
+		`{"corn": -1}`,
+	}
+	for _, data := range invalid {
+		if _, err := ParseMaterials(data); err == nil {
+			t.Errorf("ParseMaterials(%q) succeeded, expected error", data)
+		}
+	}
+}
+
+func TestAccountCharges(t *testing.T) {
+	a := NewAccount()
+	card := AllCards[0]
+	card.Charges = 2
+	a.AddCard(card)
+
+	a.UseCharge(card.Id)
+	if held := a.FindCard(card.Id); held == nil || held.Charges != 1 {
+		t.Errorf("Expected one charge left, got %+v", held)
+	}
+	a.UseCharge(card.Id)
+	if held := a.FindCard(card.Id); held != nil {
+		t.Errorf("Expected card to be used up, got %+v", held)
+	}
+
+	card.Charges = InfiniteCharges
+	a.AddCard(card)
+	a.UseCharge(card.Id)
+	if held := a.FindCard(card.Id); held == nil {
+		t.Errorf("Expected card with infinite charges to remain")
+	}
+}
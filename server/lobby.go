@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// GameSummary describes a game for players who are looking for one to join.
+type GameSummary struct {
+	Name     string     `json:"name"`
+	State    GameState  `json:"state"`
+	Players  int        `json:"players"`
+	Joinable bool       `json:"joinable"`
+	Config   GameConfig `json:"config"`
+}
+
+// Summary describes the game for the lobby.
+func (g *Game) Summary() GameSummary {
+	return GameSummary{
+		Name:     g.name,
+		State:    g.state.Name(),
+		Players:  len(g.users),
+		Joinable: g.JoinPolicy() == JoinAsPlayer,
+		Config:   g.Config,
+	}
+}
+
+// Summary returns the most recent summary of the game. It is safe to call
+// from any thread.
+func (s *GameServer) Summary() GameSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.summary
+}
+
+// updateSummary is called on the game thread after every event, to record
+// the game's summary and tell the lobby if it changed.
+func (s *GameServer) updateSummary() {
+	summary := s.game.Summary()
+	s.mu.Lock()
+	changed := summary != s.summary
+	s.summary = summary
+	s.mu.Unlock()
+
+	if changed && s.registry != nil {
+		s.registry.gameChanged(s, summary)
+	}
+}
+
+// List returns a summary of every game, in alphabetical order.
+func (r *GameRegistry) List() []GameSummary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.list()
+}
+
+// list summarizes every game. The caller must hold the lock.
+func (r *GameRegistry) list() []GameSummary {
+	games := []GameSummary{}
+	for _, name := range r.names() {
+		games = append(games, r.games[name].Summary())
+	}
+	return games
+}
+
+// Subscribe sends the connection the list of games, followed by an update
+// each time a game is created, changes, or is removed.
+func (r *GameRegistry) Subscribe(conn *Connection) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subMu.Lock()
+	defer r.subMu.Unlock()
+
+	r.subscribers[conn] = true
+	conn.Write(NewLobbyGamesMessage(r.list()))
+}
+
+// Unsubscribe stops sending updates to the connection.
+func (r *GameRegistry) Unsubscribe(conn *Connection) {
+	r.subMu.Lock()
+	defer r.subMu.Unlock()
+	delete(r.subscribers, conn)
+}
+
+// publish sends a lobby update to every subscriber.
+func (r *GameRegistry) publish(message Message) {
+	r.subMu.Lock()
+	defer r.subMu.Unlock()
+	for conn := range r.subscribers {
+		if err := conn.Write(message); err != nil {
+			delete(r.subscribers, conn)
+		}
+	}
+}
+
+// gameChanged is called by a game whenever its summary changes. Games which
+// have been retired or removed no longer appear in the lobby, so their
+// changes aren't published.
+func (r *GameRegistry) gameChanged(game *GameServer, summary GameSummary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.games[summary.Name] != game {
+		return
+	}
+	r.publish(NewLobbyGameUpdatedMessage(summary))
+}
+
+// The /games URL lists every game as JSON.
+func listGames(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(AllGames.List()); err != nil {
+		log.Printf("Unable to list games: %v", err)
+	}
+}
+
+// The /lobby URL is a websocket which sends the list of games, and then
+// pushes updates as games are created, change, or end.
+func lobby(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	conn := NewConnection(ws)
+	AllGames.Subscribe(conn)
+	defer AllGames.Unsubscribe(conn)
+	defer conn.Close()
+
+	// The lobby doesn't expect anything from the client, but reading tells
+	// us when it goes away.
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestGameSummary(t *testing.T) {
+	connection := TestConnection{}
+	game := NewGame("g", &connection, DefaultGameConfig())
+	game.RecieveMessage(&TestUser{name: "a"}, NewJoinMessage())
+
+	want := GameSummary{
+		Name:     "g",
+		State:    WaitingState,
+		Players:  1,
+		Joinable: true,
+		Config:   DefaultGameConfig(),
+	}
+	if got := game.Summary(); got != want {
+		t.Errorf("Summary() = %+v, want %+v", got, want)
+	}
+
+	game.ChangeState(ProductionState)
+	if game.Summary().Joinable {
+		t.Errorf("Expected a game in progress not to be joinable")
+	}
+}
+
+func TestListGames(t *testing.T) {
+	AllGames = NewGameRegistry(context.Background(), time.Minute)
+	defer AllGames.Shutdown()
+	AllGames.GetOrCreate("banana", DefaultGameConfig())
+	AllGames.GetOrCreate("apple", DefaultGameConfig())
+
+	server := httptest.NewServer(http.HandlerFunc(listGames))
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var games []GameSummary
+	if err := json.NewDecoder(resp.Body).Decode(&games); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(games) != 2 || games[0].Name != "apple" || games[1].Name != "banana" {
+		t.Errorf("Unexpected games: %+v", games)
+	}
+}
+
+func TestLobbyFeed(t *testing.T) {
+	AllGames = NewGameRegistry(context.Background(), time.Minute)
+	defer AllGames.Shutdown()
+	AllGames.GetOrCreate("apple", DefaultGameConfig())
+
+	server := httptest.NewServer(http.HandlerFunc(lobby))
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	list := LobbyGamesMessage{}
+	if err := conn.ReadJSON(&list); err != nil || len(list.Games) != 1 {
+		t.Fatalf("Expected the list of games, got %+v, %v", list, err)
+	}
+
+	AllGames.GetOrCreate("banana", DefaultGameConfig())
+	updated := LobbyGameUpdatedMessage{}
+	if err := conn.ReadJSON(&updated); err != nil || updated.Game.Name != "banana" {
+		t.Errorf("Expected an update for the new game, got %+v, %v", updated, err)
+	}
+
+	AllGames.Reap(time.Now().Add(time.Hour))
+	for _, name := range []string{"apple", "banana"} {
+		removed := map[string]string{}
+		if err := conn.ReadJSON(&removed); err != nil || removed["action"] != string(LobbyGameRemovedAction) {
+			t.Errorf("Expected %v to be removed, got %+v, %v", name, removed, err)
+		}
+	}
+}
+
+func TestLobbyIgnoresRetiredGames(t *testing.T) {
+	AllGames = NewGameRegistry(context.Background(), time.Minute)
+	defer AllGames.Shutdown()
+	old := AllGames.GetOrCreate("apple", DefaultGameConfig())
+
+	server := httptest.NewServer(http.HandlerFunc(lobby))
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	readUntil(t, conn, LobbyGamesAction)
+
+	old.Finish()
+	AllGames.GetOrCreate("apple", DefaultGameConfig())
+	readUntil(t, conn, LobbyGameUpdatedAction)
+
+	// Changes to the finished game don't overwrite its successor.
+	summary := old.Summary()
+	summary.State = GameOverState
+	AllGames.gameChanged(old, summary)
+	AllGames.GetOrCreate("banana", DefaultGameConfig())
+	updated := LobbyGameUpdatedMessage{}
+	if err := conn.ReadJSON(&updated); err != nil || updated.Game.Name != "banana" {
+		t.Errorf("Expected an update for banana, got %+v, %v", updated, err)
+	}
+}
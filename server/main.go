@@ -1,17 +1,19 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"github.com/gorilla/websocket"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 )
 
 var (
-	// AllGames is a map of all the games currently in progress.
-	// The key is the name of the game.
-	AllGames map[string]*GameServer
+	// AllGames is the registry of all the games currently in progress.
+	AllGames *GameRegistry
 )
 
 var upgrader = websocket.Upgrader{
@@ -20,9 +22,14 @@ var upgrader = websocket.Upgrader{
 	CheckOrigin:     func(r *http.Request) bool { return true },
 }
 
-// The /join URL takes two parameters, game, and name. The game
-// argument is optional. If specified, we'll try to join a game
-// with that name.
+// The /join URL takes five parameters, game, name, protocol, token and
+// spectate. The game argument is optional. If specified, we'll try to join a
+// game with that name, otherwise we'll create a game with a random name. The
+// protocol is the newest protocol version that the client understands, and
+// defaults to the LegacyProtocol. The token is the session token from the
+// WelcomeMessage, which a player can use to reconnect to their game. If
+// spectate is set, the player watches the game without playing. Any other
+// parameters are settings for the GameConfig of a new game.
 func join(w http.ResponseWriter, r *http.Request) {
 	params := r.URL.Query()
 	n, ok := params["name"]
@@ -31,12 +38,26 @@ func join(w http.ResponseWriter, r *http.Request) {
 		name = n[0]
 	}
 
-	t, ok := params["game"]
 	var target string
-	if ok {
+	if t, ok := params["game"]; ok {
 		target = t[0]
-	} else {
-		target = GenerateGameName()
+	}
+
+	protocol := LegacyProtocol
+	if p, ok := params["protocol"]; ok {
+		if v, err := strconv.Atoi(p[0]); err == nil && v > protocol {
+			protocol = v
+		}
+	}
+	if protocol > CurrentProtocol {
+		protocol = CurrentProtocol
+	}
+
+	// The config only applies if the game is being created.
+	config, err := ParseGameConfig(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -47,25 +68,65 @@ func join(w http.ResponseWriter, r *http.Request) {
 
 	player := Player{
 		name:       name,
-		Connection: conn,
+		protocol:   protocol,
+		Connection: NewConnection(conn),
+	}
+	if t, ok := params["token"]; ok {
+		player.token = t[0]
+	}
+	if s, ok := params["spectate"]; ok {
+		player.spectator, _ = strconv.ParseBool(s[0])
 	}
 
-	game, ok := AllGames[target]
-	if !ok {
-		// The game doesn't exist, create it.
-		game = NewGameServer(target)
-		AllGames[target] = game
+	// If the game doesn't exist, or has already ended, this creates it. The
+	// player learns the name of the game from the WelcomeMessage.
+	var game *GameServer
+	if target == "" {
+		game = AllGames.CreateRandom(config)
+	} else {
+		game = AllGames.GetOrCreate(target, config)
 	}
 	game.AddPlayer(player)
 }
 
 func main() {
 	port := flag.String("port", "8080", "the port to use to serve")
+	reapAfter := flag.Duration("reap_after", DefaultReapAfter,
+		"how long a game can be empty or finished before it is removed")
+	recordDir := flag.String("record_dir", "",
+		"a directory in which to record the events of each game")
+	replay := flag.String("replay", "",
+		"replay a recorded game and check its broadcasts, instead of serving")
 	flag.Parse()
 
-	AllGames = make(map[string]*GameServer)
+	if *replay != "" {
+		replayFile(*replay)
+		return
+	}
+
+	AllGames = NewGameRegistry(context.Background(), *reapAfter)
+	AllGames.RecordDir = *recordDir
+	defer AllGames.Shutdown()
+	go AllGames.Run()
+
 	http.HandleFunc("/join", join)
+	http.HandleFunc("/games", listGames)
+	http.HandleFunc("/lobby", lobby)
 	http.HandleFunc("/", http.FileServer(http.Dir("./web")).ServeHTTP)
 	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", *port), nil))
 
 }
+
+// replayFile replays a game recorded with -record_dir, and reports whether it
+// went the same way.
+func replayFile(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	if err := Replay(f); err != nil {
+		log.Fatalf("Replay of %q differs: %v", path, err)
+	}
+	log.Printf("Replay of %q matches the recording", path)
+}
@@ -0,0 +1,76 @@
+package main
+
+import "math"
+
+const (
+	// BasePrice is the price, in gold, of one unit of a commodity that
+	// nobody has sold recently.
+	BasePrice float64 = 10
+	// MinimumPrice is the lowest price that a commodity can fall to.
+	MinimumPrice float64 = 1
+	// PriceDrop is the fraction by which a commodity's price falls for each
+	// unit sold.
+	PriceDrop = 0.05
+	// PriceRecovery is the fraction of the gap between the current price
+	// and the BasePrice which is recovered each round.
+	PriceRecovery = 0.5
+)
+
+// Market buys commodities from players. Prices fall as more of a commodity
+// is sold, and recover towards the BasePrice between rounds.
+type Market struct {
+	prices map[CommodityType]float64
+}
+
+// NewMarket creates a market with every commodity at the BasePrice.
+func NewMarket() *Market {
+	m := Market{
+		prices: make(map[CommodityType]float64),
+	}
+	for _, c := range AllCommodities {
+		m.prices[c] = BasePrice
+	}
+	return &m
+}
+
+// Price returns the current price of one unit of the commodity.
+func (m *Market) Price(c CommodityType) int {
+	return int(math.Floor(m.prices[c]))
+}
+
+// Prices returns the current price of every commodity.
+func (m *Market) Prices() map[CommodityType]int {
+	prices := make(map[CommodityType]int)
+	for _, c := range AllCommodities {
+		prices[c] = m.Price(c)
+	}
+	return prices
+}
+
+// Sell sells a quantity of a commodity to the market, and returns the total
+// gold earned. Each unit is sold at the current price, which then drops.
+func (m *Market) Sell(c CommodityType, quantity int) int {
+	gold := 0
+	for i := 0; i < quantity; i++ {
+		gold += m.Price(c)
+		m.prices[c] = math.Max(MinimumPrice, m.prices[c]*(1-PriceDrop))
+	}
+	return gold
+}
+
+// Recover moves every price back towards the BasePrice. It returns true if
+// any quoted price changed.
+func (m *Market) Recover() bool {
+	changed := false
+	for _, c := range AllCommodities {
+		before := m.Price(c)
+		m.prices[c] += (BasePrice - m.prices[c]) * PriceRecovery
+		if BasePrice-m.prices[c] < 0.01 {
+			m.prices[c] = BasePrice
+		}
+		if m.Price(c) != before {
+			changed = true
+		}
+	}
+	return changed
+}
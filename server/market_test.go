@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestMarketPrices(t *testing.T) {
+	m := NewMarket()
+	if p := m.Price(Corn); p != int(BasePrice) {
+		t.Errorf("m.Price(Corn) = %v, want %v", p, BasePrice)
+	}
+
+	// The first unit sells at the base price, and the price drops after.
+	gold := m.Sell(Corn, 3)
+	if gold < 3*int(MinimumPrice) || gold > 3*int(BasePrice) {
+		t.Errorf("m.Sell(Corn, 3) = %v, out of range", gold)
+	}
+	if m.Price(Corn) >= int(BasePrice) {
+		t.Errorf("m.Price(Corn) = %v, expected it to drop", m.Price(Corn))
+	}
+	if m.Price(Tomato) != int(BasePrice) {
+		t.Errorf("m.Price(Tomato) = %v, expected it to stay", m.Price(Tomato))
+	}
+
+	// Selling a lot never pushes the price below the minimum.
+	m.Sell(Corn, 1000)
+	if m.Price(Corn) != int(MinimumPrice) {
+		t.Errorf("m.Price(Corn) = %v, want %v", m.Price(Corn), MinimumPrice)
+	}
+
+	// Eventually the price returns to normal.
+	if !m.Recover() {
+		t.Errorf("m.Recover() = false, expected prices to change")
+	}
+	for i := 0; i < 100; i++ {
+		m.Recover()
+	}
+	if m.Price(Corn) != int(BasePrice) {
+		t.Errorf("m.Price(Corn) = %v, want %v", m.Price(Corn), BasePrice)
+	}
+	if m.Recover() {
+		t.Errorf("m.Recover() = true, expected prices to be stable")
+	}
+}
@@ -15,15 +15,35 @@ const (
 	// Server broadcast messages
 	GameStateChangedAction MessageAction = "game_state_changed"
 	AuctionSeedAction      MessageAction = "auction_seed"
+	AskingPriceAction      MessageAction = "asking_price"
 	WelcomeAction          MessageAction = "welcome"
 	BidUpdatedAction       MessageAction = "bid_updated"
 	SetClockAction         MessageAction = "set_clock"
 	EffectAction           MessageAction = "effect_activated"
+	EffectExpiredAction    MessageAction = "effect_expired"
 	PlayerInfoUpdateAction MessageAction = "player_info_updated"
+	ProductionReportAction MessageAction = "production_report"
+	PricesUpdatedAction    MessageAction = "prices_updated"
+	StandingsAction        MessageAction = "standings"
+	GameOverAction         MessageAction = "game_over"
+	HostChangedAction      MessageAction = "host_changed"
+	PlayerKickedAction     MessageAction = "player_kicked"
+	GamePausedAction       MessageAction = "game_paused"
+	PhaseSkippedAction     MessageAction = "phase_skipped"
+	GameRestartedAction    MessageAction = "game_restarted"
+	ConfigChangedAction    MessageAction = "config_changed"
 
 	// Server-to-client messages
-	AuctionWonAction     MessageAction = "auction_won"
-	TradeCompletedAction MessageAction = "trade_completed"
+	AuctionWonAction        MessageAction = "auction_won"
+	TradeCompletedAction    MessageAction = "trade_completed"
+	TradeFailedAction       MessageAction = "trade_failed"
+	BalanceAction           MessageAction = "balance_updated"
+	ErrorAction             MessageAction = "error"
+	BidRejectedAction       MessageAction = "bid_rejected"
+	BidSealedAction         MessageAction = "bid_sealed"
+	TradeOfferUpdatedAction MessageAction = "trade_offer_updated"
+	QueuedAction            MessageAction = "queued"
+	StateSnapshotAction     MessageAction = "state_snapshot"
 
 	// Client messages
 	BidAction            MessageAction = "bid"
@@ -33,11 +53,39 @@ const (
 	TradeAction          MessageAction = "trade"
 	SetNameAction        MessageAction = "set_name"
 	ActivateEffectAction MessageAction = "activate_effect"
+	SellAction           MessageAction = "sell"
+	TradeOfferAction     MessageAction = "trade_offer"
+	TradeAcceptAction    MessageAction = "trade_accept"
+	TradeRejectAction    MessageAction = "trade_reject"
+	TradeCancelAction    MessageAction = "trade_cancel"
+	RequestSyncAction    MessageAction = "request_sync"
+
+	// Host messages
+	KickAction      MessageAction = "kick"
+	PauseAction     MessageAction = "pause"
+	ResumeAction    MessageAction = "resume"
+	SkipPhaseAction MessageAction = "skip_phase"
+	RestartAction   MessageAction = "restart"
+	ConfigureAction MessageAction = "configure"
+
+	// Lobby messages
+	LobbyGamesAction       MessageAction = "lobby_games"
+	LobbyGameUpdatedAction MessageAction = "lobby_game_updated"
+	LobbyGameRemovedAction MessageAction = "lobby_game_removed"
 
 	// Special debug-only actions
 	TickAction MessageAction = "tick"
 )
 
+const (
+	// LegacyProtocol is the protocol spoken by clients which don't ask for
+	// a particular version.
+	LegacyProtocol = 1
+	// CurrentProtocol is the newest protocol the server understands. Since
+	// version 2, materials are sent as JSON objects rather than strings.
+	CurrentProtocol = 2
+)
+
 // A Message is an object which must contain an Action string, serializable
 // to the MessageAction, and may also contain other JSON serializable fields.
 type Message interface{}
@@ -78,43 +126,89 @@ func NewGameStateChangedMessage(newState GameState) Message {
 	}
 }
 
+// AuctionSeedMessage announces the card up for auction. The seed is the
+// card's id, which older clients use to look the card up themselves.
 type AuctionSeedMessage struct {
-	Action string `json:"action"`
-	Seed   int    `json:"seed"`
+	Action string        `json:"action"`
+	Seed   int           `json:"seed"`
+	Card   Card          `json:"card"`
+	Format AuctionFormat `json:"format"`
 }
 
-func NewAuctionSeedMessage(seed int) Message {
+func NewAuctionSeedMessage(card Card, format AuctionFormat) Message {
 	return AuctionSeedMessage{
 		Action: string(AuctionSeedAction),
-		Seed:   seed,
+		Seed:   card.Id,
+		Card:   card,
+		Format: format,
 	}
 }
 
-type BidUpdatedMessage struct {
+// AskingPriceMessage announces the current price in a Dutch auction.
+type AskingPriceMessage struct {
 	Action string `json:"action"`
-	Bid    int    `json:"bid"`
-	Winner string `json:"winner"`
+	Price  int    `json:"price"`
+}
+
+func NewAskingPriceMessage(price int) Message {
+	return AskingPriceMessage{
+		Action: string(AskingPriceAction),
+		Price:  price,
+	}
+}
+
+type BidUpdatedMessage struct {
+	Action   string `json:"action"`
+	Bid      int    `json:"bid"`
+	Winner   string `json:"winner"`
+	WinnerId int    `json:"winner_id"`
 }
 
-func NewBidUpdatedMessage(bid int, winner string) Message {
-	return BidUpdatedMessage{
+// NewBidUpdatedMessage announces the current bid. The winner may be nil if
+// nobody is winning.
+func NewBidUpdatedMessage(bid int, winner User) Message {
+	m := BidUpdatedMessage{
 		Action: string(BidUpdatedAction),
 		Bid:    bid,
-		Winner: winner,
 	}
+	if winner != nil {
+		m.Winner = winner.Name()
+		m.WinnerId = winner.Id()
+	}
+	return m
 }
 
 type EffectMessage struct {
-	Action string `json:"action"`
-	Id     int    `json:"id"`
-	Author string `json:"author"`
+	Action   string `json:"action"`
+	Id       int    `json:"card_id"`
+	Author   string `json:"author"`
+	AuthorId int    `json:"author_id"`
 }
 
-func NewEffectMessage(id int, author string) Message {
+func NewEffectMessage(id int, author User) Message {
 	return EffectMessage{
-		Action: string(EffectAction),
-		Id:     id,
-		Author: author,
+		Action:   string(EffectAction),
+		Id:       id,
+		Author:   author.Name(),
+		AuthorId: author.Id(),
+	}
+}
+
+type EffectExpiredMessage struct {
+	Action   string `json:"action"`
+	Id       int    `json:"card_id"`
+	Author   string `json:"author"`
+	AuthorId int    `json:"author_id"`
+}
+
+// NewEffectExpiredMessage announces that the effect has worn off. Its author
+// may have left the game since, so they are taken from the effect.
+func NewEffectExpiredMessage(effect Effect) Message {
+	return EffectExpiredMessage{
+		Action:   string(EffectExpiredAction),
+		Id:       effect.CardId,
+		Author:   effect.Author,
+		AuthorId: effect.AuthorId,
 	}
 }
 
@@ -131,6 +225,7 @@ func NewSetClockMessage(t time.Duration) Message {
 }
 
 type PlayerInfo struct {
+	Id    int    `json:"id"`
 	Name  string `json:"name"`
 	Ready bool   `json:"ready"`
 }
@@ -147,28 +242,330 @@ func NewPlayerInfoUpdateMessage(info []PlayerInfo) Message {
 	}
 }
 
+// PlayerProduction is the output of a single player's factories.
+type PlayerProduction struct {
+	Id     int                   `json:"id"`
+	Name   string                `json:"name"`
+	Output map[CommodityType]int `json:"output"`
+}
+
+type ProductionReportMessage struct {
+	Action     string             `json:"action"`
+	Production []PlayerProduction `json:"production"`
+}
+
+func NewProductionReportMessage(production []PlayerProduction) Message {
+	return ProductionReportMessage{
+		Action:     string(ProductionReportAction),
+		Production: production,
+	}
+}
+
+type PricesUpdatedMessage struct {
+	Action string                `json:"action"`
+	Prices map[CommodityType]int `json:"prices"`
+}
+
+func NewPricesUpdatedMessage(prices map[CommodityType]int) Message {
+	return PricesUpdatedMessage{
+		Action: string(PricesUpdatedAction),
+		Prices: prices,
+	}
+}
+
+// Standing is a single player's results for the round.
+type Standing struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	Gold        int    `json:"gold"`
+	GoldEarned  int    `json:"gold_earned"`
+	Production  int    `json:"production"`
+	AuctionsWon int    `json:"auctions_won"`
+	Trades      int    `json:"trades"`
+}
+
+type StandingsMessage struct {
+	Action    string     `json:"action"`
+	Round     int        `json:"round"`
+	Standings []Standing `json:"standings"`
+}
+
+func NewStandingsMessage(round int, standings []Standing) Message {
+	return StandingsMessage{
+		Action:    string(StandingsAction),
+		Round:     round,
+		Standings: standings,
+	}
+}
+
+type GameOverMessage struct {
+	Action   string     `json:"action"`
+	Winner   string     `json:"winner"`
+	Rankings []Standing `json:"rankings"`
+}
+
+func NewGameOverMessage(winner string, rankings []Standing) Message {
+	return GameOverMessage{
+		Action:   string(GameOverAction),
+		Winner:   winner,
+		Rankings: rankings,
+	}
+}
+
 // Server-to-client messages:
 
+// HostChangedMessage announces which player is the host. HostId is zero if
+// nobody is.
+type HostChangedMessage struct {
+	Action string `json:"action"`
+	Host   string `json:"host"`
+	HostId int    `json:"host_id"`
+}
+
+func NewHostChangedMessage(host User) Message {
+	m := HostChangedMessage{Action: string(HostChangedAction)}
+	if host != nil {
+		m.Host = host.Name()
+		m.HostId = host.Id()
+	}
+	return m
+}
+
+type PlayerKickedMessage struct {
+	Action   string `json:"action"`
+	Player   string `json:"player"`
+	PlayerId int    `json:"player_id"`
+}
+
+func NewPlayerKickedMessage(player User) Message {
+	return PlayerKickedMessage{
+		Action:   string(PlayerKickedAction),
+		Player:   player.Name(),
+		PlayerId: player.Id(),
+	}
+}
+
+type GamePausedMessage struct {
+	Action string `json:"action"`
+	Paused bool   `json:"paused"`
+}
+
+func NewGamePausedMessage(paused bool) Message {
+	return GamePausedMessage{
+		Action: string(GamePausedAction),
+		Paused: paused,
+	}
+}
+
+type PhaseSkippedMessage struct {
+	Action string    `json:"action"`
+	From   GameState `json:"from"`
+}
+
+func NewPhaseSkippedMessage(from GameState) Message {
+	return PhaseSkippedMessage{
+		Action: string(PhaseSkippedAction),
+		From:   from,
+	}
+}
+
+type GameRestartedMessage struct {
+	Action string `json:"action"`
+}
+
+func NewGameRestartedMessage() Message {
+	return GameRestartedMessage{string(GameRestartedAction)}
+}
+
+type ConfigChangedMessage struct {
+	Action string     `json:"action"`
+	Config GameConfig `json:"config"`
+}
+
+func NewConfigChangedMessage(config GameConfig) Message {
+	return ConfigChangedMessage{
+		Action: string(ConfigChangedAction),
+		Config: config,
+	}
+}
+
 type TradeCompletedMessage struct {
+	Action    string    `json:"action"`
+	Materials Materials `json:"materials"`
+}
+
+func NewTradeCompletedMessage(materials Materials) Message {
+	return TradeCompletedMessage{string(TradeCompletedAction), AllMaterials(materials)}
+}
+
+// TradeFailedMessage tells a player that their shake wasn't matched with
+// anyone, or that the trade couldn't be completed.
+type TradeFailedMessage struct {
+	Action string `json:"action"`
+}
+
+func NewTradeFailedMessage() Message {
+	return TradeFailedMessage{string(TradeFailedAction)}
+}
+
+// LegacyTradeCompletedMessage is the TradeCompletedMessage understood by
+// clients using the LegacyProtocol, where the materials are a JSON string.
+type LegacyTradeCompletedMessage struct {
 	Action    string `json:"action"`
 	Materials string `json:"materials"`
 }
 
-func NewTradeCompletedMessage(materials string) Message {
-	return TradeCompletedMessage{string(TradeCompletedAction), materials}
+type BalanceMessage struct {
+	Action    string                `json:"action"`
+	Gold      int                   `json:"gold"`
+	Inventory map[CommodityType]int `json:"inventory"`
+	Factories map[CommodityType]int `json:"factories"`
+	Cards     []HeldCard            `json:"cards"`
 }
 
-type WelcomeMessage struct {
+func NewBalanceMessage(account *Account) Message {
+	return BalanceMessage{
+		Action:    string(BalanceAction),
+		Gold:      account.Gold,
+		Inventory: copyMaterials(account.Inventory),
+		Factories: copyMaterials(account.Factories),
+		Cards:     append([]HeldCard{}, account.Cards...),
+	}
+}
+
+// ErrorMessage tells a client that their last message was refused.
+type ErrorMessage struct {
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+func NewErrorMessage(reason string) Message {
+	return ErrorMessage{
+		Action: string(ErrorAction),
+		Reason: reason,
+	}
+}
+
+type BidRejectedMessage struct {
+	Action string `json:"action"`
+	Amount int    `json:"amount"`
+	Reason string `json:"reason"`
+}
+
+func NewBidRejectedMessage(amount int, reason string) Message {
+	return BidRejectedMessage{
+		Action: string(BidRejectedAction),
+		Amount: amount,
+		Reason: reason,
+	}
+}
+
+// BidSealedMessage confirms a sealed bid to the bidder.
+type BidSealedMessage struct {
+	Action string `json:"action"`
+	Amount int    `json:"amount"`
+}
+
+func NewBidSealedMessage(amount int) Message {
+	return BidSealedMessage{
+		Action: string(BidSealedAction),
+		Amount: amount,
+	}
+}
+
+// TradeOfferUpdatedMessage is sent to both parties of a trade offer, when
+// it is made and when it is resolved.
+type TradeOfferUpdatedMessage struct {
+	Action string           `json:"action"`
+	Offer  TradeOffer       `json:"offer"`
+	Status TradeOfferStatus `json:"status"`
+}
+
+func NewTradeOfferUpdatedMessage(offer TradeOffer, status TradeOfferStatus) Message {
+	return TradeOfferUpdatedMessage{
+		Action: string(TradeOfferUpdatedAction),
+		Offer:  offer,
+		Status: status,
+	}
+}
+
+// QueuedMessage tells a player who arrived during a game that they will join
+// the next one. Position is their place in the queue, starting from one.
+type QueuedMessage struct {
+	Action   string `json:"action"`
+	Position int    `json:"position"`
+}
+
+func NewQueuedMessage(position int) Message {
+	return QueuedMessage{string(QueuedAction), position}
+}
+
+// StateSnapshotMessage describes the current stage of the game, so that a
+// client can show it without waiting for the next broadcast. The game fills
+// in the state, round and clock, and the state controller fills in the rest.
+// Fields which don't apply to the current state are left out.
+type StateSnapshotMessage struct {
 	Action string `json:"action"`
-	Game   string `json:"game"`
 	State  string `json:"state"`
+	Round  int    `json:"round"`
+	Paused bool   `json:"paused,omitempty"`
+	// Time is the time left on the clock, in milliseconds.
+	Time int `json:"time"`
+
+	// Players is the ready state of each player, while waiting.
+	Players []PlayerInfo `json:"players,omitempty"`
+
+	// Card is the card on sale during the auction, and the rest describe
+	// where the bidding stands.
+	Card        *Card         `json:"card,omitempty"`
+	Format      AuctionFormat `json:"format,omitempty"`
+	Bid         int           `json:"bid,omitempty"`
+	Winner      string        `json:"winner,omitempty"`
+	WinnerId    int           `json:"winner_id,omitempty"`
+	AskingPrice int           `json:"asking_price,omitempty"`
+	SealedBid   int           `json:"sealed_bid,omitempty"`
+
+	// Offers are the pending trade offers which the player is part of.
+	Offers []TradeOffer `json:"offers,omitempty"`
+
+	// Standings are shown during the summary, and once the game is over.
+	Standings []Standing `json:"standings,omitempty"`
 }
 
-func NewWelcomeMessage(game, state string) Message {
+func NewStateSnapshotMessage(state GameState, round int, remaining time.Duration) StateSnapshotMessage {
+	return StateSnapshotMessage{
+		Action: string(StateSnapshotAction),
+		State:  string(state),
+		Round:  round,
+		Time:   int(remaining / time.Millisecond),
+	}
+}
+
+type WelcomeMessage struct {
+	Action   string   `json:"action"`
+	Game     string   `json:"game"`
+	State    string   `json:"state"`
+	Effects  []Effect `json:"effects"`
+	Protocol int      `json:"protocol"`
+	// Spectator is true if the player is only watching the game.
+	Spectator bool       `json:"spectator,omitempty"`
+	HostId    int        `json:"host_id,omitempty"`
+	Config    GameConfig `json:"config"`
+	Paused    bool       `json:"paused,omitempty"`
+	// PlayerId and Token identify the player, and the session token lets
+	// them reconnect. They are filled in when the message is sent.
+	PlayerId int    `json:"player_id,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+func NewWelcomeMessage(game, state string, effects []Effect, config GameConfig) Message {
 	return WelcomeMessage{
-		Action: string(WelcomeAction),
-		Game:   game,
-		State:  state,
+		Action:   string(WelcomeAction),
+		Game:     game,
+		State:    state,
+		Effects:  effects,
+		Protocol: CurrentProtocol,
+		Config:   config,
 	}
 }
 
@@ -222,24 +619,92 @@ func NewLeaveMessage() Message {
 	return LeaveMessage{string(LeaveAction)}
 }
 
+// TradeMessage is sent when a player shakes their phone to trade. The group
+// is an optional hint, such as a location, which limits who they can be
+// paired with.
 type TradeMessage struct {
-	Action    string `json:"action"`
-	Materials string `json:"materials"`
+	Action    string    `json:"action"`
+	Materials Materials `json:"materials"`
+	Group     string    `json:"group,omitempty"`
 }
 
-func NewTradeMessage(materials string) Message {
+func NewTradeMessage(materials Materials) Message {
 	return TradeMessage{
 		Action:    string(TradeAction),
 		Materials: materials,
 	}
 }
 
+// TradeOfferMessage proposes a trade to another player: the sender gives
+// them the Give goods in exchange for the Want goods. The recipient is
+// identified by ToId, or by name if ToId isn't given.
+type TradeOfferMessage struct {
+	Action string `json:"action"`
+	To     string `json:"to"`
+	ToId   int    `json:"to_id,omitempty"`
+	Give   Goods  `json:"give"`
+	Want   Goods  `json:"want"`
+}
+
+func NewTradeOfferMessage(to string, give, want Goods) Message {
+	return TradeOfferMessage{
+		Action: string(TradeOfferAction),
+		To:     to,
+		Give:   give,
+		Want:   want,
+	}
+}
+
+type TradeAcceptMessage struct {
+	Action  string `json:"action"`
+	OfferId int    `json:"offer_id"`
+}
+
+func NewTradeAcceptMessage(id int) Message {
+	return TradeAcceptMessage{string(TradeAcceptAction), id}
+}
+
+type TradeRejectMessage struct {
+	Action  string `json:"action"`
+	OfferId int    `json:"offer_id"`
+}
+
+func NewTradeRejectMessage(id int) Message {
+	return TradeRejectMessage{string(TradeRejectAction), id}
+}
+
+type TradeCancelMessage struct {
+	Action  string `json:"action"`
+	OfferId int    `json:"offer_id"`
+}
+
+func NewTradeCancelMessage(id int) Message {
+	return TradeCancelMessage{string(TradeCancelAction), id}
+}
+
+// RequestSyncMessage asks the server for a StateSnapshotMessage.
+type RequestSyncMessage struct {
+	Action string `json:"action"`
+}
+
+func NewRequestSyncMessage() Message {
+	return RequestSyncMessage{string(RequestSyncAction)}
+}
+
 type SellMessage struct {
 	Action   string `json:"action"`
 	Quantity int64  `json:"quantity"`
 	Type     string `json:"type"`
 }
 
+func NewSellMessage(quantity int64, commodity CommodityType) Message {
+	return SellMessage{
+		Action:   string(SellAction),
+		Quantity: quantity,
+		Type:     string(commodity),
+	}
+}
+
 type SetNameMessage struct {
 	Action string `json:"action"`
 	Name   string `json:"name"`
@@ -254,7 +719,7 @@ func NewSetNameMessage(name string) SetNameMessage {
 
 type ActivateEffectMessage struct {
 	Action  string `json:"action"`
-	Id      int    `json:"id"`
+	Id      int    `json:"card_id"`
 	Timeout int64
 }
 
@@ -266,6 +731,127 @@ func NewActivateEffectMessage(id int, timeout int64) Message {
 	}
 }
 
+// Host messages
+
+type KickMessage struct {
+	Action   string `json:"action"`
+	PlayerId int    `json:"player_id"`
+}
+
+func NewKickMessage(id int) Message {
+	return KickMessage{string(KickAction), id}
+}
+
+type PauseMessage struct {
+	Action string `json:"action"`
+}
+
+func NewPauseMessage() Message {
+	return PauseMessage{string(PauseAction)}
+}
+
+type ResumeMessage struct {
+	Action string `json:"action"`
+}
+
+func NewResumeMessage() Message {
+	return ResumeMessage{string(ResumeAction)}
+}
+
+type SkipPhaseMessage struct {
+	Action string `json:"action"`
+}
+
+func NewSkipPhaseMessage() Message {
+	return SkipPhaseMessage{string(SkipPhaseAction)}
+}
+
+type RestartMessage struct {
+	Action string `json:"action"`
+}
+
+func NewRestartMessage() Message {
+	return RestartMessage{string(RestartAction)}
+}
+
+// ConfigureMessage changes the settings of a game which hasn't begun. Only
+// the settings which are given are changed, so Config is decoded by the game.
+type ConfigureMessage struct {
+	Action string          `json:"action"`
+	Config json.RawMessage `json:"config"`
+}
+
+func NewConfigureMessage(config string) Message {
+	return ConfigureMessage{string(ConfigureAction), json.RawMessage(config)}
+}
+
+// Lobby messages
+
+type LobbyGamesMessage struct {
+	Action string        `json:"action"`
+	Games  []GameSummary `json:"games"`
+}
+
+func NewLobbyGamesMessage(games []GameSummary) Message {
+	return LobbyGamesMessage{string(LobbyGamesAction), games}
+}
+
+type LobbyGameUpdatedMessage struct {
+	Action string      `json:"action"`
+	Game   GameSummary `json:"game"`
+}
+
+func NewLobbyGameUpdatedMessage(game GameSummary) Message {
+	return LobbyGameUpdatedMessage{string(LobbyGameUpdatedAction), game}
+}
+
+type LobbyGameRemovedMessage struct {
+	Action string `json:"action"`
+	Name   string `json:"name"`
+}
+
+func NewLobbyGameRemovedMessage(name string) Message {
+	return LobbyGameRemovedMessage{string(LobbyGameRemovedAction), name}
+}
+
+// EncodeForProtocol adapts a message for a client which speaks an older
+// protocol version.
+func EncodeForProtocol(message Message, protocol int) Message {
+	switch msg := message.(type) {
+	case WelcomeMessage:
+		msg.Protocol = protocol
+		return msg
+	case TradeCompletedMessage:
+		if protocol < 2 {
+			materials, _ := json.Marshal(msg.Materials)
+			return LegacyTradeCompletedMessage{msg.Action, string(materials)}
+		}
+	}
+	return message
+}
+
+// IsGameplayMessage returns true if the message is a client action which
+// affects the game, rather than just the player's connection.
+func IsGameplayMessage(message Message) bool {
+	switch message.(type) {
+	case BidMessage, ReadyMessage, TradeMessage, SellMessage, ActivateEffectMessage,
+		TradeOfferMessage, TradeAcceptMessage, TradeRejectMessage, TradeCancelMessage:
+		return true
+	}
+	return false
+}
+
+// IsHostMessage returns true if the message is an action which only the
+// host of the game may take.
+func IsHostMessage(message Message) bool {
+	switch message.(type) {
+	case KickMessage, PauseMessage, ResumeMessage, SkipPhaseMessage, RestartMessage,
+		ConfigureMessage:
+		return true
+	}
+	return false
+}
+
 // DecodeMessage takes data in bytes, determines which message it corresponds
 // to, and decodes it to the appropriate type.
 func DecodeMessage(data []byte) (Message, error) {
@@ -287,10 +873,67 @@ func DecodeMessage(data []byte) (Message, error) {
 		m := AuctionSeedMessage{}
 		err = json.Unmarshal(data, &m)
 		message = m
+	case string(AskingPriceAction):
+		m := AskingPriceMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(BidSealedAction):
+		m := BidSealedMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
 	case string(WelcomeAction):
 		m := WelcomeMessage{}
 		err = json.Unmarshal(data, &m)
 		message = m
+	case string(ProductionReportAction):
+		m := ProductionReportMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(PricesUpdatedAction):
+		m := PricesUpdatedMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(StandingsAction):
+		m := StandingsMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(GameOverAction):
+		m := GameOverMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(HostChangedAction):
+		m := HostChangedMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(PlayerKickedAction):
+		m := PlayerKickedMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(GamePausedAction):
+		m := GamePausedMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(PhaseSkippedAction):
+		m := PhaseSkippedMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(GameRestartedAction):
+		m := GameRestartedMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(ConfigChangedAction):
+		m := ConfigChangedMessage{}
+		m.Config = DefaultGameConfig()
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(ErrorAction):
+		m := ErrorMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(EffectExpiredAction):
+		m := EffectExpiredMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
 	case string(AuctionWonAction):
 		m := AuctionWonMessage{}
 		err = json.Unmarshal(data, &m)
@@ -299,6 +942,30 @@ func DecodeMessage(data []byte) (Message, error) {
 		m := TradeCompletedMessage{}
 		err = json.Unmarshal(data, &m)
 		message = m
+	case string(TradeFailedAction):
+		m := TradeFailedMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(BalanceAction):
+		m := BalanceMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(BidRejectedAction):
+		m := BidRejectedMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(QueuedAction):
+		m := QueuedMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(StateSnapshotAction):
+		m := StateSnapshotMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(TradeOfferUpdatedAction):
+		m := TradeOfferUpdatedMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
 	case string(BidAction):
 		m := BidMessage{}
 		err = json.Unmarshal(data, &m)
@@ -331,6 +998,54 @@ func DecodeMessage(data []byte) (Message, error) {
 		m := ActivateEffectMessage{}
 		err = json.Unmarshal(data, &m)
 		message = m
+	case string(SellAction):
+		m := SellMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(TradeOfferAction):
+		m := TradeOfferMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(TradeAcceptAction):
+		m := TradeAcceptMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(TradeRejectAction):
+		m := TradeRejectMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(TradeCancelAction):
+		m := TradeCancelMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(RequestSyncAction):
+		m := RequestSyncMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(KickAction):
+		m := KickMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(PauseAction):
+		m := PauseMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(ResumeAction):
+		m := ResumeMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(SkipPhaseAction):
+		m := SkipPhaseMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(RestartAction):
+		m := RestartMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
+	case string(ConfigureAction):
+		m := ConfigureMessage{}
+		err = json.Unmarshal(data, &m)
+		message = m
 	default:
 		err = fmt.Errorf("Unknown action: %v", msg.Action)
 	}
@@ -3,16 +3,58 @@ package main
 import "testing"
 
 func TestMessageDecoding(t *testing.T) {
-	data := []byte(`{"action": "trade", "materials": "a hammer"}`)
+	data := []byte(`{"action": "trade", "materials": {"corn": 2}}`)
 	msg, err := DecodeMessage(data)
 	if err != nil {
 		t.Errorf("DecodeMessage(...) returned err: %v", err)
 	}
 	trade := msg.(TradeMessage)
 
-	want := "a hammer"
-	if trade.Materials != want {
-		t.Errorf("trade.Materials = %q, want %q", trade.Materials, want)
+	want := 2
+	if trade.Materials[Corn] != want {
+		t.Errorf("trade.Materials[Corn] = %v, want %v", trade.Materials[Corn], want)
+	}
+}
+
+func TestLegacyMaterialsDecoding(t *testing.T) {
+	// Older clients send the materials as a JSON encoded string.
+	data := []byte(`{"action": "trade", "materials": "{\"tomato\": 3}"}`)
+	msg, err := DecodeMessage(data)
+	if err != nil {
+		t.Errorf("DecodeMessage(...) returned err: %v", err)
+	}
+	trade := msg.(TradeMessage)
+
+	want := 3
+	if trade.Materials[Tomato] != want {
+		t.Errorf("trade.Materials[Tomato] = %v, want %v", trade.Materials[Tomato], want)
+	}
+}
+
+func TestInvalidMaterialsDecoding(t *testing.T) {
+	invalid := []string{
+		`{"action": "trade", "materials": "a hammer"}`,
+		`{"action": "trade", "materials": {"hammer": 1}}`,
+		`{"action": "trade", "materials": {"corn": -1}}`,
+	}
+	for _, data := range invalid {
+		if _, err := DecodeMessage([]byte(data)); err == nil {
+			t.Errorf("DecodeMessage(%s) succeeded, expected error", data)
+		}
+	}
+}
+
+func TestEncodeForProtocol(t *testing.T) {
+	msg := NewTradeCompletedMessage(Materials{Corn: 1})
+
+	if _, ok := EncodeForProtocol(msg, CurrentProtocol).(TradeCompletedMessage); !ok {
+		t.Errorf("EncodeForProtocol(...) changed the message for the current protocol")
+	}
+
+	legacy := EncodeForProtocol(msg, LegacyProtocol).(LegacyTradeCompletedMessage)
+	want := `{"blueberry":0,"corn":1,"purple":0,"tomato":0}`
+	if legacy.Materials != want {
+		t.Errorf("legacy.Materials = %q, want %q", legacy.Materials, want)
 	}
 }
 
@@ -30,3 +72,16 @@ func TestBidMessageDecoding(t *testing.T) {
 		t.Errorf("bid.Amount = %q, want %q", bid.Amount, want)
 	}
 }
+
+func TestSellMessageDecoding(t *testing.T) {
+	data := []byte(`{"action": "sell", "type": "corn", "quantity": 3}`)
+	msg, err := DecodeMessage(data)
+	if err != nil {
+		t.Errorf("DecodeMessage(...) returned err: %v", err)
+	}
+
+	sell := msg.(SellMessage)
+	if sell.Type != string(Corn) || sell.Quantity != 3 {
+		t.Errorf("DecodeMessage(...) = %v, want 3 corn", sell)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// gameNameAdjectives and gameNameFruits are short, easy to say words which
+// make up game names.
+var (
+	gameNameAdjectives = []string{
+		"big", "blue", "bold", "brave", "calm", "cool", "fast", "fuzzy",
+		"green", "happy", "jolly", "lucky", "merry", "proud", "quick",
+		"red", "shy", "sunny", "sweet", "tiny", "wild", "wise",
+	}
+	gameNameFruits = []string{
+		"apple", "banana", "berry", "cherry", "coconut", "grape", "guava",
+		"kiwi", "lemon", "lime", "mango", "melon", "olive", "papaya",
+		"peach", "pear", "plum", "tomato",
+	}
+)
+
+// GenerateGameName generates a random name for the game, in case
+// the user didn't specify one when they connected. Names look like
+// "sunny-mango-42". The registry checks that the name isn't taken.
+func GenerateGameName() string {
+	return fmt.Sprintf("%s-%s-%d",
+		gameNameAdjectives[rand.Intn(len(gameNameAdjectives))],
+		gameNameFruits[rand.Intn(len(gameNameFruits))],
+		rand.Intn(90)+10,
+	)
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestGenerateGameName(t *testing.T) {
+	pattern := regexp.MustCompile(`^[a-z]+-[a-z]+-[0-9]{2}$`)
+	for i := 0; i < 100; i++ {
+		name := GenerateGameName()
+		if !pattern.MatchString(name) {
+			t.Errorf("Unexpected game name: %q", name)
+		}
+	}
+}
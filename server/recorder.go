@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RecordingHeader is the first line of a game's event log. It holds
+// everything needed to create the game again before its events are replayed.
+type RecordingHeader struct {
+	Time   time.Time  `json:"time"`
+	Game   string     `json:"game"`
+	Seed   int64      `json:"seed"`
+	Config GameConfig `json:"config"`
+}
+
+// RecordedEvent is a single line of a game's event log. It is either a
+// message which the game received, or a message which it broadcast.
+// Messages without a player are ticks.
+type RecordedEvent struct {
+	Time     time.Time `json:"time"`
+	PlayerId int       `json:"player_id,omitempty"`
+	Name     string    `json:"name,omitempty"`
+	// Spectator is true if the message is a player joining as a spectator.
+	Spectator bool            `json:"spectator,omitempty"`
+	Message   json.RawMessage `json:"message,omitempty"`
+	Broadcast json.RawMessage `json:"broadcast,omitempty"`
+}
+
+// Recorder writes a game's event log as JSON Lines, so that the game can be
+// replayed later. A nil Recorder records nothing, and a Recorder which fails
+// to write stops recording.
+type Recorder struct {
+	w   io.WriteCloser
+	enc *json.Encoder
+	err error
+}
+
+// NewRecorder begins a log of the game's events, by writing its header.
+func NewRecorder(w io.WriteCloser, game *Game) *Recorder {
+	r := &Recorder{w: w, enc: json.NewEncoder(w)}
+	r.write(RecordingHeader{
+		Time:   time.Now(),
+		Game:   game.name,
+		Seed:   game.Seed(),
+		Config: game.Config,
+	})
+	return r
+}
+
+// CreateRecorder begins a log of the game's events in a new file in the
+// directory.
+func CreateRecorder(dir string, game *Game) (*Recorder, error) {
+	name := fmt.Sprintf("%v-%v.jsonl", url.PathEscape(game.name), time.Now().UnixNano())
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	return NewRecorder(f, game), nil
+}
+
+func (r *Recorder) write(v interface{}) {
+	if r.err != nil {
+		return
+	}
+	if r.err = r.enc.Encode(v); r.err != nil {
+		log.Printf("Unable to record game: %v", r.err)
+	}
+}
+
+// newRecordedEvent records a message from the user, or a tick if the user is
+// nil.
+func newRecordedEvent(u User, m Message) RecordedEvent {
+	data, err := json.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	event := RecordedEvent{Time: time.Now(), Message: data}
+	if u != nil {
+		event.PlayerId = u.Id()
+		event.Name = u.Name()
+	}
+	return event
+}
+
+// Record records a message which the game received from the user. The user
+// is nil for ticks.
+func (r *Recorder) Record(u User, m Message) {
+	if r == nil {
+		return
+	}
+	r.write(newRecordedEvent(u, m))
+}
+
+// RecordSpectator records the user joining the game as a spectator.
+func (r *Recorder) RecordSpectator(u User) {
+	if r == nil {
+		return
+	}
+	event := newRecordedEvent(u, NewJoinMessage())
+	event.Spectator = true
+	r.write(event)
+}
+
+// RecordBroadcast records a message which the game broadcast.
+func (r *Recorder) RecordBroadcast(m Message) {
+	if r == nil {
+		return
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	r.write(RecordedEvent{Time: time.Now(), Broadcast: data})
+}
+
+// Close finishes the log.
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	return r.w.Close()
+}
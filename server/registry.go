@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultReapAfter is how long a game may sit empty or finished before it
+// is removed from the registry.
+const DefaultReapAfter time.Duration = 10 * time.Minute
+
+// GameRegistry keeps track of every game on the server. It is safe to use
+// from multiple goroutines.
+type GameRegistry struct {
+	mu        sync.Mutex
+	ctx       context.Context
+	games     map[string]*GameServer
+	reapAfter time.Duration
+	// RecordDir is the directory in which each game's events are recorded.
+	// Games aren't recorded if it's empty.
+	RecordDir string
+	// retired are finished games which have been succeeded by a new game
+	// of the same name, but still have players looking at the results.
+	retired []*GameServer
+
+	// subMu guards the lobby subscribers. When both locks are needed, mu
+	// must be taken first.
+	subMu       sync.Mutex
+	subscribers map[*Connection]bool
+}
+
+// NewGameRegistry creates an empty registry. Games are stopped once the
+// context is cancelled, and removed once they have been idle for reapAfter.
+func NewGameRegistry(ctx context.Context, reapAfter time.Duration) *GameRegistry {
+	return &GameRegistry{
+		ctx:         ctx,
+		games:       make(map[string]*GameServer),
+		reapAfter:   reapAfter,
+		subscribers: make(map[*Connection]bool),
+	}
+}
+
+// GetOrCreate returns the game with the given name, creating it with the
+// config if it doesn't exist. A game which has finished is replaced with a
+// new one, but keeps running until it is reaped, like in Successor.
+func (r *GameRegistry) GetOrCreate(name string, config GameConfig) *GameServer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	game, ok := r.games[name]
+	if ok && !game.IsFinished() {
+		// The caller is about to add a player, so the game mustn't be
+		// reaped in the meantime.
+		game.touch()
+		return game
+	}
+	if ok {
+		return r.replace(game, config)
+	}
+	return r.create(name, config)
+}
+
+// CreateRandom creates a game with a random name which isn't already in use.
+func (r *GameRegistry) CreateRandom(config GameConfig) *GameServer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := GenerateGameName()
+	for _, ok := r.games[name]; ok; _, ok = r.games[name] {
+		name = GenerateGameName()
+	}
+	return r.create(name, config)
+}
+
+// create starts a new game. The caller must hold the lock.
+func (r *GameRegistry) create(name string, config GameConfig) *GameServer {
+	log.Printf("Creating game %q", name)
+	game := NewGameServer(r.ctx, name, config, r)
+	r.games[name] = game
+	r.publish(NewLobbyGameUpdatedMessage(game.Summary()))
+	return game
+}
+
+// Successor replaces a finished game with a new game of the same name and
+// config, and returns it. The finished game keeps running until it is
+// reaped, so that its players can see the results.
+func (r *GameRegistry) Successor(old *GameServer, config GameConfig) *GameServer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if current, ok := r.games[old.game.name]; ok && current != old {
+		// The game has already been replaced.
+		return current
+	}
+	return r.replace(old, config)
+}
+
+// replace retires a finished game, and creates a new game of the same name
+// in its place. The caller must hold the lock.
+func (r *GameRegistry) replace(old *GameServer, config GameConfig) *GameServer {
+	r.retired = append(r.retired, old)
+	return r.create(old.game.name, config)
+}
+
+// remove stops a game and takes it out of the registry. The caller must
+// hold the lock.
+func (r *GameRegistry) remove(name string) {
+	r.games[name].Stop()
+	delete(r.games, name)
+	r.publish(NewLobbyGameRemovedMessage(name))
+}
+
+// Lookup returns the game with the given name, if it exists.
+func (r *GameRegistry) Lookup(name string) (*GameServer, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	game, ok := r.games[name]
+	return game, ok
+}
+
+// Names returns the names of every game, in alphabetical order.
+func (r *GameRegistry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.names()
+}
+
+// names lists the games. The caller must hold the lock.
+func (r *GameRegistry) names() []string {
+	var names []string
+	for name := range r.games {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reap stops and removes every game which has been empty or finished for
+// longer than the reapAfter duration.
+func (r *GameRegistry) Reap(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, game := range r.games {
+		since, idle := game.IdleSince()
+		if idle && now.Sub(since) >= r.reapAfter {
+			log.Printf("Reaping game %q, idle since %v", name, since)
+			r.remove(name)
+		}
+	}
+
+	var retired []*GameServer
+	for _, game := range r.retired {
+		since, idle := game.IdleSince()
+		if idle && now.Sub(since) >= r.reapAfter {
+			game.Stop()
+		} else {
+			retired = append(retired, game)
+		}
+	}
+	r.retired = retired
+}
+
+// Run reaps idle games periodically, until the registry's context is
+// cancelled.
+func (r *GameRegistry) Run() {
+	ticker := time.NewTicker(r.reapAfter / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			r.Reap(now)
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+// Shutdown stops and removes every game.
+func (r *GameRegistry) Shutdown() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name := range r.games {
+		r.remove(name)
+	}
+	for _, game := range r.retired {
+		game.Stop()
+	}
+	r.retired = nil
+}
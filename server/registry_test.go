@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRegistryGetOrCreate(t *testing.T) {
+	r := NewGameRegistry(context.Background(), time.Minute)
+	defer r.Shutdown()
+
+	a := r.GetOrCreate("apple", DefaultGameConfig())
+	if b := r.GetOrCreate("apple", DefaultGameConfig()); a != b {
+		t.Errorf("Expected the same game to be returned")
+	}
+	if g, ok := r.Lookup("apple"); !ok || g != a {
+		t.Errorf("Expected to find the game by name")
+	}
+	if _, ok := r.Lookup("banana"); ok {
+		t.Errorf("Expected no game called banana")
+	}
+
+	r.GetOrCreate("banana", DefaultGameConfig())
+	names := r.Names()
+	if len(names) != 2 || names[0] != "apple" || names[1] != "banana" {
+		t.Errorf("Expected [apple banana], got %v", names)
+	}
+}
+
+func TestRegistryReplacesFinishedGame(t *testing.T) {
+	r := NewGameRegistry(context.Background(), time.Minute)
+	defer r.Shutdown()
+
+	a := r.GetOrCreate("apple", DefaultGameConfig())
+	a.Finish()
+	b := r.GetOrCreate("apple", DefaultGameConfig())
+	if a == b {
+		t.Errorf("Expected the finished game to be replaced")
+	}
+
+	// The finished game keeps running until it is reaped, so that its
+	// players can see the results.
+	select {
+	case <-a.Done():
+		t.Errorf("Expected the finished game not to be stopped yet")
+	default:
+	}
+	since, _ := a.IdleSince()
+	r.Reap(since.Add(time.Minute))
+	select {
+	case <-a.Done():
+	case <-time.After(time.Second):
+		t.Errorf("Expected the finished game to be stopped once reaped")
+	}
+}
+
+func TestRegistryReap(t *testing.T) {
+	r := NewGameRegistry(context.Background(), time.Minute)
+	defer r.Shutdown()
+
+	game := r.GetOrCreate("apple", DefaultGameConfig())
+	since, idle := game.IdleSince()
+	if !idle {
+		t.Fatalf("Expected an empty game to be idle")
+	}
+
+	r.Reap(since.Add(30 * time.Second))
+	if _, ok := r.Lookup("apple"); !ok {
+		t.Errorf("Expected the game not to be reaped yet")
+	}
+
+	r.Reap(since.Add(time.Minute))
+	if _, ok := r.Lookup("apple"); ok {
+		t.Errorf("Expected the game to be reaped")
+	}
+	select {
+	case <-game.Done():
+	case <-time.After(time.Second):
+		t.Errorf("Expected the reaped game to be stopped")
+	}
+}
+
+func TestRegistryGetOrCreateDelaysReap(t *testing.T) {
+	r := NewGameRegistry(context.Background(), time.Minute)
+	defer r.Shutdown()
+
+	r.GetOrCreate("apple", DefaultGameConfig())
+	game, _ := r.Lookup("apple")
+	since, _ := game.IdleSince()
+
+	// Somebody joining an idle game restarts its idle time.
+	time.Sleep(time.Millisecond)
+	r.GetOrCreate("apple", DefaultGameConfig())
+	r.Reap(since.Add(time.Minute))
+	if _, ok := r.Lookup("apple"); !ok {
+		t.Errorf("Expected the game not to be reaped while a player joins")
+	}
+}
+
+func TestRegistryShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := NewGameRegistry(ctx, time.Minute)
+	game := r.GetOrCreate("apple", DefaultGameConfig())
+
+	cancel()
+	select {
+	case <-game.Done():
+	case <-time.After(time.Second):
+		t.Errorf("Expected cancelling the context to stop the game")
+	}
+
+	r.Shutdown()
+	if len(r.Names()) != 0 {
+		t.Errorf("Expected no games after shutdown")
+	}
+}
+
+func TestRegistryCreateRandom(t *testing.T) {
+	r := NewGameRegistry(context.Background(), time.Minute)
+	defer r.Shutdown()
+
+	seen := make(map[*GameServer]bool)
+	for i := 0; i < 20; i++ {
+		game := r.CreateRandom(DefaultGameConfig())
+		if seen[game] {
+			t.Fatalf("Expected a new game each time")
+		}
+		seen[game] = true
+	}
+	if len(r.Names()) != 20 {
+		t.Errorf("Expected 20 distinct names, got %v", r.Names())
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// replayConnection is the GameConnection of a replayed game. It keeps a log
+// of what the game broadcasts, to compare against the recording.
+type replayConnection struct {
+	broadcastLog []string
+}
+
+func (c *replayConnection) Broadcast(message Message) error {
+	result, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	c.broadcastLog = append(c.broadcastLog, string(result))
+	return nil
+}
+
+func (c *replayConnection) Finish() {}
+
+func (c *replayConnection) Kick(user User) {}
+
+// replayUser stands in for a recorded player. Messages sent only to them
+// weren't recorded, so they are thrown away.
+type replayUser struct {
+	id   int
+	name string
+}
+
+func (u *replayUser) Message(message Message) error { return nil }
+func (u *replayUser) Id() int                       { return u.id }
+func (u *replayUser) Name() string                  { return u.name }
+func (u *replayUser) SetName(name string)           { u.name = name }
+
+// Replay feeds a game's event log back through a new Game, and checks that
+// the game broadcasts the same messages as it did when it was recorded. The
+// first difference is returned as an error.
+func Replay(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	header := RecordingHeader{}
+	if err := dec.Decode(&header); err != nil {
+		return fmt.Errorf("Unable to read header: %v", err)
+	}
+	connection := &replayConnection{}
+	game := NewGame(header.Game, connection, header.Config)
+	game.SetSeed(header.Seed)
+
+	users := make(map[int]*replayUser)
+	var want []string
+	for line := 2; ; line++ {
+		event := RecordedEvent{}
+		err := dec.Decode(&event)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("Line %v: %v", line, err)
+		}
+		if event.Broadcast != nil {
+			want = append(want, string(event.Broadcast))
+			continue
+		}
+
+		// Everything broadcast so far must match before the game moves on.
+		if err := compareBroadcasts(connection.broadcastLog, want); err != nil {
+			return fmt.Errorf("Before line %v: %v", line, err)
+		}
+
+		message, err := DecodeMessage(event.Message)
+		if err != nil {
+			return fmt.Errorf("Line %v: %v", line, err)
+		}
+		if tick, ok := message.(TickMessage); ok {
+			game.Tick(time.Duration(tick.Tick) * time.Millisecond)
+			continue
+		}
+		user, ok := users[event.PlayerId]
+		if !ok {
+			user = &replayUser{id: event.PlayerId, name: event.Name}
+			users[event.PlayerId] = user
+		}
+		if event.Spectator {
+			game.Spectate(user)
+		} else {
+			game.RecieveMessage(user, message)
+		}
+	}
+	if err := compareBroadcasts(connection.broadcastLog, want); err != nil {
+		return fmt.Errorf("At the end: %v", err)
+	}
+	return nil
+}
+
+// compareBroadcasts returns an error describing the first difference between
+// the broadcasts which were replayed and those which were recorded.
+func compareBroadcasts(got, want []string) error {
+	for i := 0; i < len(got) && i < len(want); i++ {
+		if got[i] != want[i] {
+			return fmt.Errorf("Broadcast %v was %s, but %s was recorded", i+1, got[i], want[i])
+		}
+	}
+	if len(got) > len(want) {
+		return fmt.Errorf("Unexpected broadcast %v: %s", len(want)+1, got[len(want)])
+	}
+	if len(got) < len(want) {
+		return fmt.Errorf("Missing broadcast %v: %s", len(got)+1, want[len(got)])
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	server := &GameServer{finished: make(chan struct{})}
+	config := DefaultGameConfig()
+	config.MaxRounds = 1
+	server.game = NewGame("g", server, config)
+	recorder, err := CreateRecorder(dir, server.game)
+	if err != nil {
+		t.Fatalf("CreateRecorder: %v", err)
+	}
+	server.recorder = recorder
+
+	// Play a short game, in which somebody wins an auction.
+	alice := &Player{id: 1, name: "alice"}
+	bob := &Player{id: 2, name: "bob"}
+	server.deliver(alice, NewJoinMessage())
+	server.deliver(bob, NewJoinMessage())
+	server.deliver(bob, NewSetNameMessage("robert"))
+	server.deliver(alice, NewReadyMessage(true))
+	server.deliver(bob, NewReadyMessage(true))
+	clock := time.Duration(0)
+	for server.game.state.Name() != GameOverState {
+		clock += TickInterval
+		server.tick(NewTickMessage(clock))
+		if server.game.state.Name() == AuctionState {
+			server.deliver(bob, NewBidMessage(5))
+		}
+	}
+	server.recorder.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "g-*.jsonl"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one recording, found %v", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if err := Replay(strings.NewReader(string(data))); err != nil {
+		t.Errorf("Replay: %v", err)
+	}
+
+	// If the game went differently, the replay says so.
+	tampered := strings.Replace(string(data), `"winner":"robert"`, `"winner":"alice"`, 1)
+	if tampered == string(data) {
+		t.Fatalf("Expected robert to win a bid")
+	}
+	if err := Replay(strings.NewReader(tampered)); err == nil {
+		t.Errorf("Expected the tampered recording not to match")
+	}
+}
+
+// Messages which the server turned away, or which the game refused, don't
+// stop the recording from being replayed.
+func TestReplayRejectedMessages(t *testing.T) {
+	AllGames = NewGameRegistry(context.Background(), time.Minute)
+	AllGames.RecordDir = t.TempDir()
+	defer AllGames.Shutdown()
+	server := httptest.NewServer(http.HandlerFunc(join))
+	defer server.Close()
+
+	conn, _ := dial(t, server, "game=g&name=alice")
+	defer conn.Close()
+	for _, message := range []string{
+		`{"action":"trade","materials":{"hammer":1}}`,
+		`{"action":"trade"}`,
+		`{"action":"tick","tick_ms":1e12}`,
+		`{"action":"sell","type":"corn","quantity":1}`,
+		`{"action":"unknown"}`,
+	} {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	// The market is closed, so the sale is refused.
+	readUntil(t, conn, ErrorAction)
+
+	// The recording is finished once the game stops and closes the
+	// connection.
+	AllGames.Shutdown()
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(AllGames.RecordDir, "g-*.jsonl"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one recording, found %v", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if err := Replay(strings.NewReader(string(data))); err != nil {
+		t.Errorf("Replay: %v", err)
+	}
+}
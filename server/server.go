@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -11,12 +16,33 @@ const (
 	// TickInterval is the nominal time between ticks. All timing is done in
 	// increments of the TickInterval. It's kind of like the frame rate.
 	TickInterval time.Duration = 300 * time.Millisecond
+	// ReconnectGracePeriod is how long a player who loses their connection
+	// has to reconnect before they are removed from the game.
+	ReconnectGracePeriod time.Duration = 60 * time.Second
 )
 
-// Player is an implementation of User with websockets.
+// Player is an implementation of User with websockets. There is one Player
+// for each person in the game, which is kept across reconnections.
 type Player struct {
+	id         int
 	name       string
-	Connection *websocket.Conn
+	protocol   int
+	Connection *Connection
+
+	// token is the session token which lets the player reconnect.
+	token string
+	// spectator is true if the player is only watching the game.
+	spectator bool
+	// disconnectedAt is the game time at which the player's connection was
+	// lost. It is only meaningful while Connection is nil.
+	disconnectedAt time.Duration
+	// moved tells the thread reading the player's connection that they have
+	// been moved on to another game. It is made along with that thread.
+	moved chan *GameServer
+}
+
+func (p *Player) Id() int {
+	return p.id
 }
 
 func (p *Player) Name() string {
@@ -27,15 +53,27 @@ func (p *Player) SetName(name string) {
 	p.name = name
 }
 
-// Message sends a player a message.
+// Message sends a player a message, in the protocol version that they
+// speak. The WelcomeMessage carries the player's Id and session token.
 func (p *Player) Message(message Message) error {
-	return p.Connection.WriteJSON(message)
+	if p.Connection == nil {
+		return errors.New("player is disconnected")
+	}
+	if msg, ok := message.(WelcomeMessage); ok {
+		msg.PlayerId = p.id
+		msg.Token = p.token
+		message = msg
+	}
+	return p.Connection.Write(EncodeForProtocol(message, p.protocol))
 }
 
-// GenerateGameName generates a random name for the game, in case
-// the user didn't specify one when they connected.
-func GenerateGameName() string {
-	return "test"
+// GenerateToken generates a random session token.
+func GenerateToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatalf("Unable to generate session token: %v", err)
+	}
+	return hex.EncodeToString(b)
 }
 
 // An Event is a combination of a Message and the Player who originated the
@@ -43,6 +81,8 @@ func GenerateGameName() string {
 type Event struct {
 	Message Message
 	Player  *Player
+	// Connection is the connection the message arrived on, if any.
+	Connection *Connection
 }
 
 // NewEvent constructs an Event.
@@ -53,17 +93,164 @@ func NewEvent(player *Player, message Message) Event {
 	}
 }
 
-// A GameServer is an instance of a GameConnection.
+// A GameServer is an instance of a GameConnection. It runs the game on its
+// own goroutines until it is stopped.
 type GameServer struct {
-	players          []Player
+	players          []*Player
 	game             *Game
 	incomingMessages chan Event
+	finished         chan struct{}
+	nextPlayerId     int
+	ctx              context.Context
+	stop             context.CancelFunc
+	// registry is told when the game changes, and provides the next game
+	// for queued players. It may be nil.
+	registry *GameRegistry
+	// queue holds the players waiting for the next game to begin.
+	queue []*Player
+	// moved holds the queued players who were moved on to the next game
+	// when this one finished, so that anything they sent on the way is
+	// passed along.
+	moved map[*Player]*GameServer
+	// recorder logs the game's events, if it is being recorded.
+	recorder *Recorder
+
+	// mu guards idleSince and summary, which are read by the registry.
+	mu        sync.Mutex
+	idleSince time.Time
+	summary   GameSummary
+}
+
+// Finish is called by the game once it is over.
+func (s *GameServer) Finish() {
+	log.Printf("Game %q is finished", s.game.name)
+	close(s.finished)
+	s.setIdle(true)
+
+	// Anybody waiting for the next game moves on to it.
+	if len(s.queue) == 0 {
+		return
+	}
+	if s.registry == nil {
+		for _, p := range s.queue {
+			p.Connection.Close()
+		}
+		s.queue = nil
+		return
+	}
+	next := s.registry.Successor(s, s.game.Config)
+	s.moved = make(map[*Player]*GameServer)
+	for _, p := range s.queue {
+		s.move(p, next)
+	}
+	s.queue = nil
+}
+
+// move hands a queued player over to the next game. The thread reading their
+// connection sends to the next game from then on.
+func (s *GameServer) move(player *Player, next *GameServer) {
+	joined := next.send(Event{
+		Player:     player,
+		Message:    NewJoinMessage(),
+		Connection: player.Connection,
+	})
+	if !joined {
+		player.Connection.Close()
+		return
+	}
+	s.moved[player] = next
+	select {
+	case <-player.moved:
+	default:
+	}
+	player.moved <- next
+}
+
+// IsFinished returns true if the game is over.
+func (s *GameServer) IsFinished() bool {
+	select {
+	case <-s.finished:
+		return true
+	default:
+		return false
+	}
+}
+
+// IdleSince returns the time since which the game has been empty or
+// finished. If the game is in use, it returns false.
+func (s *GameServer) IdleSince() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.idleSince, !s.idleSince.IsZero()
+}
+
+// touch restarts the idle time of a game which is idle, so that it isn't
+// reaped while somebody is on their way in.
+func (s *GameServer) touch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.idleSince.IsZero() {
+		s.idleSince = time.Now()
+	}
+}
+
+func (s *GameServer) setIdle(idle bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !idle {
+		s.idleSince = time.Time{}
+	} else if s.idleSince.IsZero() {
+		s.idleSince = time.Now()
+	}
+}
+
+// Kick removes a player from the game, and closes their connection. The game
+// has already dealt with them leaving.
+func (s *GameServer) Kick(user User) {
+	for i, p := range s.players {
+		if p.Id() != user.Id() {
+			continue
+		}
+		s.players = append(s.players[:i], s.players[i+1:]...)
+		if p.Connection != nil {
+			p.Connection.Close()
+		}
+		break
+	}
+	if len(s.players) == 0 {
+		s.setIdle(true)
+	}
+}
+
+// Stop shuts down the game's goroutines, and disconnects its players.
+func (s *GameServer) Stop() {
+	s.stop()
+}
+
+// Done returns a channel which is closed once the game has been stopped.
+func (s *GameServer) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// send queues an event for the game thread, unless the game has stopped. It
+// returns false if the event was dropped.
+func (s *GameServer) send(event Event) bool {
+	select {
+	case s.incomingMessages <- event:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
 }
 
 // Broadcast sends a message to every Player.
 func (s *GameServer) Broadcast(message Message) error {
+	s.recorder.RecordBroadcast(message)
 	log.Printf("Broadcast: %v", message)
 	for _, p := range s.players {
+		if p.Connection == nil {
+			continue
+		}
 		err := p.Message(message)
 		if err != nil {
 			log.Printf("Write failed during broadcast: %v\n", err)
@@ -74,91 +261,322 @@ func (s *GameServer) Broadcast(message Message) error {
 
 // AddPlayer is called by the main thread to add a player to our game. In fact, it
 // queues a JoinMessage from this new player, which our game thread picks up.
+// If the player presents the token of a player who is still in the game, they
+// take that player's place. If the game has already stopped, the player's
+// connection is closed.
 func (s *GameServer) AddPlayer(player Player) {
 	log.Printf("Adding new player %q to game %q", player.Name(), s.game.name)
 
-	s.incomingMessages <- NewEvent(&player, NewJoinMessage())
+	joined := s.send(Event{
+		Player:     &player,
+		Message:    NewJoinMessage(),
+		Connection: player.Connection,
+	})
+	if !joined {
+		log.Printf("Game %q stopped before player %q could join", s.game.name, player.Name())
+		player.Connection.Close()
+	}
 }
 
-// HandleCommunication is the main game loop which reads messages from players.
-// This thread is where all of the game state logic is called from, including
-// timer callbacks, etc.
-func (s *GameServer) HandleCommunication(player Player) {
-	// Send a join message as we arrive.
-	s.incomingMessages <- NewEvent(&player, NewJoinMessage())
-
+// HandleCommunication reads messages from a player's connection, and sends
+// them over to the game thread to be handled. It is called on a new thread
+// for each connection. If the player is moved on to another game, their
+// messages follow them.
+func (s *GameServer) HandleCommunication(player *Player, conn *Connection) {
+	// The player's name belongs to the game thread, so log the address.
+	name := conn.RemoteAddr()
 	for {
-		t, data, err := player.Connection.ReadMessage()
+		t, data, err := conn.ReadMessage()
+		select {
+		case next := <-player.moved:
+			s = next
+		default:
+		}
 		if err != nil {
-			log.Printf("Websocket[name=%v] read error: %v", player.Name(), err)
-			s.incomingMessages <- NewEvent(&player, NewLeaveMessage())
+			log.Printf("Websocket[addr=%v] read error: %v", name, err)
+			s.send(Event{
+				Player:     player,
+				Message:    NewLeaveMessage(),
+				Connection: conn,
+			})
 			return
 		}
 
 		if t != websocket.TextMessage {
-			log.Printf("Websocket[name=%v] sent binary message", player.Name())
+			log.Printf("Websocket[addr=%v] sent binary message", name)
 		}
 
 		msg, err := DecodeMessage(data)
-		log.Printf("Player[name=%v] sent message: %v", player.Name(), msg)
 		if err != nil {
-			log.Printf("Websocket[name=%v] sent invalid message: %v", player.Name(), err)
+			log.Printf("Websocket[addr=%v] sent invalid message: %v", name, err)
+			continue
+		}
+		log.Printf("Player[addr=%v] sent message: %v", name, msg)
+		s.send(NewEvent(player, msg))
+	}
+}
+
+// findPlayer returns the player in the game with the given session token.
+func (s *GameServer) findPlayer(token string) *Player {
+	if token == "" {
+		return nil
+	}
+	for _, p := range s.players {
+		if p.token == token {
+			return p
+		}
+	}
+	return nil
+}
+
+// join adds a newly connected player to the game, or reattaches them to
+// their old player if they are reconnecting.
+func (s *GameServer) join(player *Player, conn *Connection) {
+	if p := s.findPlayer(player.token); p != nil {
+		log.Printf("Player %q reconnected to game %q", p.Name(), s.game.name)
+		if p.Connection != nil {
+			p.Connection.Close()
 		}
-		s.incomingMessages <- NewEvent(&player, msg)
+		p.Connection = conn
+		p.protocol = player.protocol
+		go s.HandleCommunication(p, conn)
+		s.game.Resync(p)
+		return
+	}
+
+	policy := s.game.JoinPolicy()
+	if player.spectator {
+		policy = JoinAsSpectator
+	}
+	switch policy {
+	case RejectJoin:
+		log.Printf("Player %q turned away from game %q", player.Name(), s.game.name)
+		player.Message(NewErrorMessage("The game has already begun."))
+		conn.CloseWhenSent()
+		return
+	case QueueJoin:
+		log.Printf("Player %q queued for game %q", player.Name(), s.game.name)
+		s.queue = append(s.queue, player)
+		// The connection is read while they wait, to keep it alive and to
+		// notice if they leave.
+		s.read(player, conn)
+		player.Message(NewQueuedMessage(len(s.queue)))
+		return
+	case JoinAsSpectator:
+		player.spectator = true
 	}
+
+	s.nextPlayerId++
+	player.id = s.nextPlayerId
+	player.token = GenerateToken()
+	s.players = append(s.players, player)
+	s.setIdle(false)
+	s.read(player, conn)
+	if player.spectator {
+		s.recorder.RecordSpectator(player)
+		s.game.Spectate(player)
+	} else {
+		s.deliver(player, NewJoinMessage())
+	}
+}
+
+// read starts the thread which reads a new player's connection, unless it's
+// already running because they were queued.
+func (s *GameServer) read(player *Player, conn *Connection) {
+	if player.moved != nil {
+		return
+	}
+	player.moved = make(chan *GameServer, 1)
+	go s.HandleCommunication(player, conn)
+}
+
+// queuePosition returns the index of the player in the queue, or -1 if they
+// aren't queued.
+func (s *GameServer) queuePosition(player *Player) int {
+	for i, p := range s.queue {
+		if p == player {
+			return i
+		}
+	}
+	return -1
+}
+
+// leaveQueue takes the player at index i out of the queue, and closes their
+// connection.
+func (s *GameServer) leaveQueue(i int) {
+	p := s.queue[i]
+	log.Printf("Player %q left the queue for game %q", p.Name(), s.game.name)
+	s.queue = append(s.queue[:i], s.queue[i+1:]...)
+	p.Connection.Close()
+	p.Connection = nil
 }
 
-// HandleMessages is called on a new thread, once for each Player. It simply gets
-// messages from the Player and sends them over to the game thread to be handled.
+// admitQueue lets the queued players in, once the game is waiting to begin.
+func (s *GameServer) admitQueue() {
+	if len(s.queue) == 0 || s.game.state.Name() != WaitingState {
+		return
+	}
+	queue := s.queue
+	s.queue = nil
+	for _, p := range queue {
+		s.join(p, p.Connection)
+	}
+}
+
+// disconnect is called when a player's connection is lost. They are given
+// the ReconnectGracePeriod to reconnect before they leave the game.
+func (s *GameServer) disconnect(player *Player, conn *Connection) {
+	if player.Connection != conn {
+		// The player has already reconnected on another connection.
+		return
+	}
+	log.Printf("Player %q disconnected from game %q", player.Name(), s.game.name)
+	conn.Close()
+	player.Connection = nil
+	player.disconnectedAt = s.game.GetTime()
+}
+
+// deliver passes a message from the player to the game, and records it.
+func (s *GameServer) deliver(player *Player, message Message) {
+	s.recorder.Record(player, message)
+	s.game.RecieveMessage(player, message)
+}
+
+// tick advances the game clock, and removes anybody who hasn't reconnected
+// in time.
+func (s *GameServer) tick(msg TickMessage) {
+	s.recorder.Record(nil, msg)
+	s.game.Tick(time.Duration(msg.Tick) * time.Millisecond)
+	s.removeDisconnected()
+}
+
+// remove takes a player out of the game.
+func (s *GameServer) remove(player *Player) {
+	for i, p := range s.players {
+		if p == player {
+			s.players = append(s.players[:i], s.players[i+1:]...)
+			break
+		}
+	}
+	if len(s.players) == 0 {
+		s.setIdle(true)
+	}
+	s.deliver(player, NewLeaveMessage())
+}
+
+// removeDisconnected removes the players whose grace period has run out.
+func (s *GameServer) removeDisconnected() {
+	var expired []*Player
+	for _, p := range s.players {
+		if p.Connection == nil && s.game.GetTime()-p.disconnectedAt >= ReconnectGracePeriod {
+			expired = append(expired, p)
+		}
+	}
+	for _, p := range expired {
+		log.Printf("Player %q did not reconnect to game %q", p.Name(), s.game.name)
+		s.remove(p)
+	}
+}
+
+// HandleMessages is the main game loop. This thread is where all of the game
+// state logic is called from, including timer callbacks, etc.
 func (s *GameServer) HandleMessages() {
 	for {
-		event := <-s.incomingMessages
+		var event Event
+		select {
+		case event = <-s.incomingMessages:
+		case <-s.ctx.Done():
+			// Closing the connections stops each player's reader.
+			for _, p := range s.players {
+				if p.Connection != nil {
+					p.Connection.Close()
+				}
+			}
+			for _, p := range s.queue {
+				p.Connection.Close()
+			}
+			s.recorder.Close()
+			return
+		}
+
+		if next, ok := s.moved[event.Player]; ok {
+			next.send(event)
+			continue
+		}
+		if i := s.queuePosition(event.Player); i >= 0 {
+			// Queued players can only leave.
+			if _, ok := event.Message.(LeaveMessage); ok {
+				s.leaveQueue(i)
+			}
+			continue
+		}
+
 		switch msg := event.Message.(type) {
 		case TickMessage:
-			s.game.Tick(time.Duration(msg.Tick) * time.Millisecond)
+			// Only the clock may move the game along, not the players.
+			if event.Player == nil {
+				s.tick(msg)
+			}
 		case JoinMessage:
-			new := true
-			for _, x := range s.players {
-				if *event.Player == x {
-					new = false
-					break
-				}
+			if event.Connection != nil {
+				s.join(event.Player, event.Connection)
+			} else {
+				s.deliver(event.Player, event.Message)
 			}
-
-			if new {
-				// On the first pass, set up the player and begin handling
-				// their messages for them.
-				s.players = append(s.players, *event.Player)
-				go s.HandleCommunication(*event.Player)
+		case LeaveMessage:
+			if event.Connection != nil {
+				s.disconnect(event.Player, event.Connection)
 			} else {
-				// On subsequent passes, we just want to send the message
-				// through to the game controller.
-				s.game.RecieveMessage(event.Player, event.Message)
+				s.deliver(event.Player, event.Message)
 			}
 		default:
-			s.game.RecieveMessage(event.Player, event.Message)
+			s.deliver(event.Player, event.Message)
 		}
+		s.admitQueue()
+		s.updateSummary()
 	}
 }
 
 // RunClock is a dedicated thread which sends tick messages at the TickInterval.
 func (s *GameServer) RunClock() {
+	ticker := time.NewTicker(TickInterval)
+	defer ticker.Stop()
+
 	ticks := 0 * time.Second
 	for {
-		time.Sleep(TickInterval)
-		ticks += TickInterval
-		s.incomingMessages <- NewEvent(nil, NewTickMessage(ticks))
+		select {
+		case <-ticker.C:
+			ticks += TickInterval
+			s.send(NewEvent(nil, NewTickMessage(ticks)))
+		case <-s.ctx.Done():
+			return
+		}
 	}
 }
 
 // NewGameServer constructs a game server object, initializes the threads which it
-// needs to handle messages and the game clock.
-func NewGameServer(name string) *GameServer {
+// needs to handle messages and the game clock. The threads run until the game
+// is stopped, or the parent context is cancelled. The registry may be nil.
+func NewGameServer(parent context.Context, name string, config GameConfig, registry *GameRegistry) *GameServer {
+	ctx, stop := context.WithCancel(parent)
 	g := GameServer{
 		game:             nil,
 		incomingMessages: make(chan Event),
+		finished:         make(chan struct{}),
+		ctx:              ctx,
+		stop:             stop,
+		registry:         registry,
+		idleSince:        time.Now(),
+	}
+	g.game = NewGame(name, &g, config)
+	if registry != nil && registry.RecordDir != "" {
+		recorder, err := CreateRecorder(registry.RecordDir, g.game)
+		if err != nil {
+			log.Printf("Unable to record game %q: %v", name, err)
+		}
+		g.recorder = recorder
 	}
-	g.game = NewGame(name, &g)
+	g.summary = g.game.Summary()
 
 	go g.HandleMessages()
 	go g.RunClock()
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dial connects to the /join handler of a test server, and returns the
+// connection along with the WelcomeMessage which it receives.
+func dial(t *testing.T, server *httptest.Server, query string) (*websocket.Conn, WelcomeMessage) {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/join?" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial(%q): %v", url, err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	welcome := WelcomeMessage{}
+	if err := conn.ReadJSON(&welcome); err != nil {
+		t.Fatalf("Reading welcome: %v", err)
+	}
+	return conn, welcome
+}
+
+func TestReconnect(t *testing.T) {
+	AllGames = NewGameRegistry(context.Background(), time.Minute)
+	defer AllGames.Shutdown()
+	server := httptest.NewServer(http.HandlerFunc(join))
+	defer server.Close()
+
+	conn, welcome := dial(t, server, "game=g&name=alice&max_rounds=4")
+	if welcome.Config.MaxRounds != 4 {
+		t.Errorf("Expected the config to be echoed, got %+v", welcome.Config)
+	}
+	if welcome.Token == "" || welcome.PlayerId == 0 {
+		t.Fatalf("Expected a player id and token in the welcome message")
+	}
+	conn.Close()
+
+	conn, again := dial(t, server, "game=g&name=alice&token="+welcome.Token)
+	defer conn.Close()
+	if again.Token != welcome.Token || again.PlayerId != welcome.PlayerId || again.State != string(WaitingState) {
+		t.Errorf("Reconnect: got %+v, want token %q", again, welcome.Token)
+	}
+
+	// Somebody presenting an unknown token joins as a new player.
+	other, fresh := dial(t, server, "game=g&name=bob&token=bogus")
+	defer other.Close()
+	if fresh.Token == "" || fresh.Token == welcome.Token || fresh.Token == "bogus" {
+		t.Errorf("Expected a new token, got %q", fresh.Token)
+	}
+}
+
+func TestSlowConnectionDropped(t *testing.T) {
+	upgraded := make(chan *websocket.Conn)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Upgrade: %v", err)
+			return
+		}
+		upgraded <- ws
+	}))
+	defer server.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer client.Close()
+
+	// Without a writer draining the queue, the client falls behind.
+	c := &Connection{
+		ws:   <-upgraded,
+		send: make(chan []byte, 1),
+		done: make(chan struct{}),
+	}
+	if err := c.Write(NewJoinMessage()); err != nil {
+		t.Errorf("First write: %v", err)
+	}
+	if err := c.Write(NewJoinMessage()); err == nil {
+		t.Errorf("Expected the write to a full queue to fail")
+	}
+	select {
+	case <-c.done:
+	default:
+		t.Errorf("Expected the slow connection to be closed")
+	}
+	if err := c.Write(NewJoinMessage()); err == nil {
+		t.Errorf("Expected the write to a closed connection to fail")
+	}
+}
+
+func TestJoinStoppedGame(t *testing.T) {
+	game := NewGameServer(context.Background(), "g", DefaultGameConfig(), nil)
+	game.Stop()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Upgrade: %v", err)
+			return
+		}
+		game.AddPlayer(Player{name: "late", Connection: NewConnection(ws)})
+	}))
+	defer server.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer client.Close()
+
+	// A player who arrives after the game stopped isn't left hanging.
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = client.ReadMessage()
+	if ne, ok := err.(net.Error); err == nil || ok && ne.Timeout() {
+		t.Errorf("Expected the connection to be closed, got %v", err)
+	}
+}
+
+func TestJoinAsSpectator(t *testing.T) {
+	AllGames = NewGameRegistry(context.Background(), time.Minute)
+	defer AllGames.Shutdown()
+	server := httptest.NewServer(http.HandlerFunc(join))
+	defer server.Close()
+
+	conn, welcome := dial(t, server, "game=g&name=tv&spectate=1")
+	defer conn.Close()
+	if !welcome.Spectator {
+		t.Errorf("Expected to join as a spectator, got %+v", welcome)
+	}
+}
+
+// readUntil reads messages from the connection until one with the given
+// action arrives, and returns it.
+func readUntil(t *testing.T, conn *websocket.Conn, action MessageAction) map[string]interface{} {
+	for {
+		message := map[string]interface{}{}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("Waiting for %q: %v", action, err)
+		}
+		if message["action"] == string(action) {
+			return message
+		}
+	}
+}
+
+// startGame joins a new game with the given settings, and readies up so that
+// the game begins.
+func startGame(t *testing.T, server *httptest.Server, query string) *websocket.Conn {
+	conn, _ := dial(t, server, query+"&min_players=1")
+	if err := conn.WriteJSON(NewReadyMessage(true)); err != nil {
+		t.Fatalf("Ready: %v", err)
+	}
+	readUntil(t, conn, GameStateChangedAction)
+	return conn
+}
+
+func TestJoinPolicies(t *testing.T) {
+	AllGames = NewGameRegistry(context.Background(), time.Minute)
+	defer AllGames.Shutdown()
+	server := httptest.NewServer(http.HandlerFunc(join))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/join?name=late&game="
+
+	// By default, late arrivals watch the game in progress.
+	host := startGame(t, server, "game=s&name=host")
+	defer host.Close()
+	conn, welcome := dial(t, server, "game=s&name=late")
+	if !welcome.Spectator || welcome.State != string(ProductionState) {
+		t.Errorf("Expected to spectate the production stage, got %+v", welcome)
+	}
+	readUntil(t, conn, StandingsAction)
+	conn.Close()
+
+	// Games may turn them away instead.
+	host = startGame(t, server, "game=r&name=host&join_policy=reject")
+	defer host.Close()
+	conn, _, err := websocket.DefaultDialer.Dial(url+"r", nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	rejected := readUntil(t, conn, ErrorAction)
+	if rejected["reason"] != "The game has already begun." {
+		t.Errorf("Unexpected rejection: %v", rejected)
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("Expected the connection to be closed, got %v", err)
+	}
+	conn.Close()
+
+	// Or they may wait for the next game.
+	host = startGame(t, server, "game=q&name=host&join_policy=queue")
+	defer host.Close()
+	conn, _, err = websocket.DefaultDialer.Dial(url+"q", nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	queued := readUntil(t, conn, QueuedAction)
+	if queued["position"] != 1.0 {
+		t.Errorf("Unexpected queue position: %v", queued)
+	}
+	if err := host.WriteJSON(NewRestartMessage()); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	admitted := readUntil(t, conn, WelcomeAction)
+	if admitted["spectator"] == true || admitted["state"] != string(WaitingState) {
+		t.Errorf("Expected to join the next game, got %v", admitted)
+	}
+}
+
+// Queued players may wait for longer than the read deadline, since their
+// connection is read while they wait.
+func TestQueueOutlastsReadDeadline(t *testing.T) {
+	defer func(timeout time.Duration) { PongTimeout = timeout }(PongTimeout)
+	PongTimeout = 200 * time.Millisecond
+
+	AllGames = NewGameRegistry(context.Background(), time.Minute)
+	defer AllGames.Shutdown()
+	server := httptest.NewServer(http.HandlerFunc(join))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/join?game=q&name="
+
+	host := startGame(t, server, "game=q&name=host&join_policy=queue")
+	defer host.Close()
+	// The host keeps reading, so that they answer pings.
+	host.SetReadDeadline(time.Time{})
+	go func() {
+		for {
+			if _, _, err := host.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"late", nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	readUntil(t, conn, QueuedAction)
+
+	// Somebody who gives up waiting isn't let in.
+	quitter, _, err := websocket.DefaultDialer.Dial(url+"quitter", nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	quitter.SetReadDeadline(time.Now().Add(time.Second))
+	readUntil(t, quitter, QueuedAction)
+	quitter.Close()
+
+	time.AfterFunc(3*PongTimeout, func() {
+		host.WriteJSON(NewRestartMessage())
+	})
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	readUntil(t, conn, WelcomeAction)
+
+	// The connection still works once they're in.
+	if err := conn.WriteJSON(NewRequestSyncMessage()); err != nil {
+		t.Fatalf("RequestSync: %v", err)
+	}
+	readUntil(t, conn, StateSnapshotAction)
+	game, _ := AllGames.Lookup("q")
+	if players := game.Summary().Players; players != 2 {
+		t.Errorf("Expected the host and one queued player, got %v players", players)
+	}
+}
+
+// Messages which fail to decode are dropped, rather than being passed on
+// without the parts which were invalid.
+func TestInvalidMessagesDropped(t *testing.T) {
+	AllGames = NewGameRegistry(context.Background(), time.Minute)
+	defer AllGames.Shutdown()
+	server := httptest.NewServer(http.HandlerFunc(join))
+	defer server.Close()
+
+	host, _ := dial(t, server, "game=t&name=host&min_players=2&trade_timeout=10s")
+	defer host.Close()
+	guest, _ := dial(t, server, "game=t&name=guest")
+	defer guest.Close()
+	for _, conn := range []*websocket.Conn{host, guest} {
+		if err := conn.WriteJSON(NewReadyMessage(true)); err != nil {
+			t.Fatalf("Ready: %v", err)
+		}
+	}
+	host.WriteJSON(NewSkipPhaseMessage())
+	host.WriteJSON(NewSkipPhaseMessage())
+	for state := ""; state != string(TradeState); {
+		state, _ = readUntil(t, host, GameStateChangedAction)["new_state"].(string)
+	}
+
+	for _, conn := range []*websocket.Conn{host, guest} {
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"action":"trade","materials":{"hammer":1}}`))
+	}
+	host.WriteMessage(websocket.TextMessage, []byte(
+		`{"action":"trade_offer","to":"guest","give":{"materials":{"corn":-1}},"want":{}}`))
+
+	for _, conn := range []*websocket.Conn{host, guest} {
+		conn.WriteJSON(NewRequestSyncMessage())
+		for {
+			message := map[string]interface{}{}
+			if err := conn.ReadJSON(&message); err != nil {
+				t.Fatalf("Waiting for the snapshot: %v", err)
+			}
+			action := message["action"]
+			if action == string(TradeCompletedAction) || action == string(TradeOfferUpdatedAction) {
+				t.Errorf("Expected no trade, got %v", message)
+			}
+			if action == string(StateSnapshotAction) {
+				if message["offers"] != nil {
+					t.Errorf("Expected no offers, got %v", message["offers"])
+				}
+				break
+			}
+		}
+	}
+}
+
+func TestClientTickIgnored(t *testing.T) {
+	AllGames = NewGameRegistry(context.Background(), time.Minute)
+	defer AllGames.Shutdown()
+	server := httptest.NewServer(http.HandlerFunc(join))
+	defer server.Close()
+
+	conn := startGame(t, server, "game=t&name=host")
+	defer conn.Close()
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"action":"tick","tick_ms":1e12}`))
+	conn.WriteJSON(NewRequestSyncMessage())
+	snapshot := readUntil(t, conn, StateSnapshotAction)
+	if snapshot["state"] != string(ProductionState) {
+		t.Errorf("Expected the game to stay in the production stage, got %v", snapshot)
+	}
+}
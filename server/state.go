@@ -2,19 +2,25 @@ package main
 
 import (
 	"log"
+	"math"
 	"math/rand"
+	"sort"
 	"time"
 )
 
 type GameState string
 
 const (
-	WaitingState GameState = "waiting"
-	AuctionState GameState = "auction"
-	TradeState   GameState = "trade"
-	SummaryState GameState = "summary"
+	WaitingState    GameState = "waiting"
+	ProductionState GameState = "production"
+	AuctionState    GameState = "auction"
+	TradeState      GameState = "trade"
+	SummaryState    GameState = "summary"
+	GameOverState   GameState = "game_over"
 )
 
+// These are the default settings of a game. Each game reads its settings
+// from its GameConfig.
 const (
 	// ProductionTimeout is how long the production phase will last before
 	// the next phase begins.
@@ -36,6 +42,12 @@ const (
 	// MinPlayers sets the minimum number of players required before the game
 	// will proceed past the Waiting stage.
 	MinPlayers int = 1
+	// GoldTarget is the amount of gold a player needs to win the game. If
+	// zero, the game doesn't end on gold.
+	GoldTarget int = 200
+	// MaxRounds is the number of rounds after which the game ends. If zero,
+	// there is no round limit.
+	MaxRounds int = 10
 )
 
 type StateController interface {
@@ -44,27 +56,56 @@ type StateController interface {
 	End()
 	Timer(tick time.Duration)
 	RecieveMessage(User, Message)
+	// Snapshot fills in the parts of a StateSnapshotMessage which describe
+	// this state, as the given user should see it.
+	Snapshot(u User, snapshot *StateSnapshotMessage)
+}
+
+// A TickingController is a StateController which also needs to be told
+// about every tick, for things which happen on a shorter timescale than
+// the state's timer.
+type TickingController interface {
+	Tick(tick time.Duration)
+}
+
+// A ResyncingController is a StateController which has state of its own to
+// send to a player who has reconnected.
+type ResyncingController interface {
+	Resync(u User)
 }
 
 type WaitingController struct {
 	game  *Game
 	name  GameState
-	ready map[User]bool
+	ready map[int]bool
+	users map[int]User
 }
 
 func NewWaitingController(game *Game) *WaitingController {
 	return &WaitingController{
 		game:  game,
 		name:  WaitingState,
-		ready: map[User]bool{},
+		ready: map[int]bool{},
+		users: map[int]User{},
 	}
 }
 
 // Name returns the name of the current state.
 func (s *WaitingController) Name() GameState { return s.name }
 
-// Begin is called when the state becomes active.
-func (s *WaitingController) Begin() {}
+// Begin is called when the state becomes active. If the game has been
+// restarted, everyone who is still here needs to get ready again.
+func (s *WaitingController) Begin() {
+	users := s.game.Users()
+	if len(users) == 0 {
+		return
+	}
+	for _, u := range users {
+		s.ready[u.Id()] = false
+		s.users[u.Id()] = u
+	}
+	s.broadcastInfo()
+}
 
 // End is called when the state is no longer active.
 func (s *WaitingController) End() {}
@@ -77,11 +118,14 @@ func (s *WaitingController) RecieveMessage(u User, m Message) {
 	log.Printf("Ready state: %v", s.ready)
 	switch msg := m.(type) {
 	case ReadyMessage:
-		s.ready[u] = msg.Ready
+		s.ready[u.Id()] = msg.Ready
+		s.users[u.Id()] = u
 	case JoinMessage:
-		s.ready[u] = false
+		s.ready[u.Id()] = false
+		s.users[u.Id()] = u
 	case LeaveMessage:
-		delete(s.ready, u)
+		delete(s.ready, u.Id())
+		delete(s.users, u.Id())
 	case SetNameMessage:
 		// Just send a playerinfo update (done below),
 		// no need to take action, since
@@ -90,17 +134,34 @@ func (s *WaitingController) RecieveMessage(u User, m Message) {
 		return
 	}
 
-	// Inform all of the clients of the ready state of the other
-	// clients.
+	s.broadcastInfo()
+	s.proceedIfReady()
+}
+
+// Snapshot lists who is ready.
+func (s *WaitingController) Snapshot(u User, snapshot *StateSnapshotMessage) {
+	snapshot.Players = s.info()
+}
+
+// info returns the ready state of each client, in the order that they
+// joined.
+func (s *WaitingController) info() []PlayerInfo {
 	var info []PlayerInfo
-	for u, ready := range s.ready {
+	for id, ready := range s.ready {
 		info = append(info, PlayerInfo{
-			Name:  u.Name(),
+			Id:    id,
+			Name:  s.users[id].Name(),
 			Ready: ready,
 		})
 	}
-	s.game.connection.Broadcast(NewPlayerInfoUpdateMessage(info))
-	s.proceedIfReady()
+	sort.Slice(info, func(i, j int) bool { return info[i].Id < info[j].Id })
+	return info
+}
+
+// broadcastInfo informs all of the clients of the ready state of the other
+// clients.
+func (s *WaitingController) broadcastInfo() {
+	s.game.connection.Broadcast(NewPlayerInfoUpdateMessage(s.info()))
 }
 
 func (s *WaitingController) proceedIfReady() {
@@ -112,148 +173,98 @@ func (s *WaitingController) proceedIfReady() {
 		count++
 	}
 
-	if count >= s.game.MinPlayers {
-		s.game.ChangeState(AuctionState)
+	if count >= s.game.Config.MinPlayers {
+		s.game.ChangeState(ProductionState)
 	}
 }
 
-type AuctionController struct {
-	name   GameState
-	game   *Game
-	bid    int
-	step   int
-	steps  int
-	winner User
+// ProductionController manages the production phase at the start of each
+// round, during which every player's factories yield commodities.
+type ProductionController struct {
+	name GameState
+	game *Game
 }
 
-func NewAuctionController(game *Game) *AuctionController {
-	return &AuctionController{
-		name:  AuctionState,
-		game:  game,
-		steps: NumberOfBids,
+// NewProductionController creates a ProductionController instance.
+func NewProductionController(game *Game) *ProductionController {
+	return &ProductionController{
+		name: ProductionState,
+		game: game,
 	}
 }
 
 // Name returns the name of the current state.
-func (s *AuctionController) Name() GameState { return s.name }
+func (s *ProductionController) Name() GameState { return s.name }
 
 // Begin is called when the state becomes active.
-func (s *AuctionController) Begin() {
-	s.issueCard()
-}
-
-func (s *AuctionController) issueCard() {
-	// When the auction begins, we need to choose a random number and broadcast
-	// it to the participants.
-	seed := rand.Int()
-	s.game.connection.Broadcast(
-		NewAuctionSeedMessage(seed),
-	)
-
-	// Set a timeout, and update player clocks.
-	s.game.SetTimeout(AuctionBidTime)
-	s.game.connection.Broadcast(NewSetClockMessage(AuctionBidTime))
-}
-
-// End is called when the state is no longer active.
-func (s *AuctionController) End() {}
-
-// Timer is only used to determine when the auction is over. So when we get
-// this call, the current auction is over.
-func (s *AuctionController) Timer(tick time.Duration) {
-	if s.winner != nil {
-		s.winner.Message(NewAuctionWonMessage())
+func (s *ProductionController) Begin() {
+	s.game.round++
+	for _, u := range s.game.Users() {
+		s.game.Account(u).BeginRound()
 	}
 
-	// Reset the bid and winner.
-	s.bid = 0
-	s.winner = nil
-
-	s.step++
-	if s.step == s.steps {
-		// We've reached the end of the auction process. So let's change
-		// phases. The TradeState is next.
-		s.game.ChangeState(TradeState)
-	} else {
-		// Issue the next card.
-		s.issueCard()
+	// Prices recover a little at the start of each round.
+	if s.game.market.Recover() {
+		s.game.connection.Broadcast(NewPricesUpdatedMessage(s.game.market.Prices()))
 	}
-}
 
-// RecieveMessage is called when a new message is sent by a user.
-func (s *AuctionController) RecieveMessage(u User, m Message) {
-	switch msg := m.(type) {
-	case BidMessage:
-		if msg.Amount > s.bid {
-			s.bid = msg.Amount
-			s.winner = u
-			s.game.SetTimeout(AuctionBidTime)
-
-			// Update everyone on the new bid and winner.
-			s.game.connection.Broadcast(NewBidUpdatedMessage(s.bid, u.Name()))
-			s.game.connection.Broadcast(NewSetClockMessage(AuctionBidTime))
+	var report []PlayerProduction
+	for _, u := range s.game.Users() {
+		account := s.game.Account(u)
+		output := make(map[CommodityType]int)
+		for _, c := range AllCommodities {
+			output[c] = produce(s.game.rand, account.Factories[c], s.game.YieldRate(c))
+			account.Round.Production += output[c]
 		}
+		account.Credit(0, output)
+		s.game.SendBalance(u)
+
+		report = append(report, PlayerProduction{
+			Id:     u.Id(),
+			Name:   u.Name(),
+			Output: output,
+		})
 	}
-}
+	s.game.connection.Broadcast(NewProductionReportMessage(report))
+
+	// Effects last for a number of productions, so count them down now.
+	s.game.ExpireEffects()
 
-// TradeController manages the state of the game during trading.
-type TradeController struct {
-	name            GameState
-	game            *Game
-	stagedMaterials string
-	stagedUser      User
-	stagingTime     time.Duration
+	s.game.SetTimeout(s.game.Config.ProductionTimeout)
+	s.game.connection.Broadcast(NewSetClockMessage(s.game.Config.ProductionTimeout))
 }
 
-// NewTradeController creates a TradeController instance.
-func NewTradeController(game *Game) *TradeController {
-	return &TradeController{
-		name: TradeState,
-		game: game,
+// produce computes the output of a number of factories at the given yield
+// rate. Each factory yields the whole part of the rate, plus one more with
+// probability equal to the fractional part, so the average output matches
+// the rate.
+func produce(r *rand.Rand, factories int, rate float64) int {
+	whole := math.Floor(rate)
+	fraction := rate - whole
+
+	total := 0
+	for i := 0; i < factories; i++ {
+		total += int(whole)
+		if fraction > 0 && r.Float64() < fraction {
+			total++
+		}
 	}
+	return total
 }
 
-// Name returns the name of the current state.
-func (s *TradeController) Name() GameState { return s.name }
-
-// Begin is called when the state becomes active.
-func (s *TradeController) Begin() {
-	// The trading stage ends after a certain time.
-	s.game.SetTimeout(TradingStageTime)
-	s.game.connection.Broadcast(NewSetClockMessage(TradingStageTime))
-}
+// End is called when the state is no longer active.
+func (s *ProductionController) End() {}
 
 // Timer is called when the stage is over, so just begin next stage.
-func (s *TradeController) Timer(tick time.Duration) {
-	// TODO: change this to SummaryState when UI has support for it.
+func (s *ProductionController) Timer(tick time.Duration) {
 	s.game.ChangeState(AuctionState)
 }
 
-// End is called when the state is no longer active.
-func (s *TradeController) End() {}
-
 // RecieveMessage is called when a user sends the server a message.
-func (s *TradeController) RecieveMessage(u User, m Message) {
-	switch msg := m.(type) {
-	case TradeMessage:
-		isntSelfTrade := s.stagedUser != u
-		withinTimeInterval := s.game.GetTime()-s.stagingTime < TradeTimeout
-		if isntSelfTrade && s.stagedUser != nil && withinTimeInterval {
-			// Execute the currently proposed trade.
-			s.stagedUser.Message(NewTradeCompletedMessage(msg.Materials))
-			u.Message(NewTradeCompletedMessage(s.stagedMaterials))
-
-			// Reset the staged materials
-			s.stagedUser = nil
-			s.stagingTime = 0
-			s.stagedMaterials = ""
-		} else {
-			s.stagedUser = u
-			s.stagingTime = s.game.GetTime()
-			s.stagedMaterials = msg.Materials
-		}
-	}
-}
+func (s *ProductionController) RecieveMessage(u User, m Message) {}
+
+// Snapshot has nothing to add, since production happens all at once.
+func (s *ProductionController) Snapshot(u User, snapshot *StateSnapshotMessage) {}
 
 // SummaryController manages the game state during the end-of-turn summary screen.
 type SummaryController struct {
@@ -273,8 +284,12 @@ func (s *SummaryController) Name() GameState { return s.name }
 
 // Begin is called when the stage becomes active.
 func (s *SummaryController) Begin() {
-	s.game.SetTimeout(SummaryStageTime)
-	s.game.connection.Broadcast(NewSetClockMessage(SummaryStageTime))
+	s.game.connection.Broadcast(
+		NewStandingsMessage(s.game.round, s.game.Standings()),
+	)
+
+	s.game.SetTimeout(s.game.Config.SummaryStageTime)
+	s.game.connection.Broadcast(NewSetClockMessage(s.game.Config.SummaryStageTime))
 }
 
 // End is called when the stage is no longer active.
@@ -283,22 +298,89 @@ func (s *SummaryController) End() {}
 // RecieveMessage is called when the user sends the server a message.
 func (s *SummaryController) RecieveMessage(u User, m Message) {}
 
-// Timer is called when the stage is over, so just begin next stage.
+// Snapshot adds the standings for the round.
+func (s *SummaryController) Snapshot(u User, snapshot *StateSnapshotMessage) {
+	snapshot.Standings = s.game.Standings()
+}
+
+// Timer is called when the stage is over, so begin the next round, unless
+// somebody has won.
 func (s *SummaryController) Timer(tick time.Duration) {
-	s.game.ChangeState(AuctionState)
+	if s.game.IsOver() {
+		s.game.ChangeState(GameOverState)
+	} else {
+		s.game.ChangeState(ProductionState)
+	}
+}
+
+// GameOverController is the final state of the game. Nothing else happens
+// once it is reached.
+type GameOverController struct {
+	name GameState
+	game *Game
+}
+
+// NewGameOverController creates a GameOverController instance.
+func NewGameOverController(game *Game) *GameOverController {
+	return &GameOverController{
+		name: GameOverState,
+		game: game,
+	}
 }
 
+// Name returns the name of the current state.
+func (s *GameOverController) Name() GameState { return s.name }
+
+// Begin is called when the stage becomes active.
+func (s *GameOverController) Begin() {
+	s.game.connection.Broadcast(s.result())
+	s.game.connection.Finish()
+}
+
+func (s *GameOverController) result() Message {
+	rankings := s.game.Standings()
+	winner := ""
+	if len(rankings) > 0 {
+		winner = rankings[0].Name
+	}
+	return NewGameOverMessage(winner, rankings)
+}
+
+// End is called when the stage is no longer active.
+func (s *GameOverController) End() {}
+
+// RecieveMessage is called when the user sends the server a message. Players
+// who arrive late are shown the result when they join.
+func (s *GameOverController) RecieveMessage(u User, m Message) {}
+
+// Resync shows a reconnecting player who won.
+func (s *GameOverController) Resync(u User) {
+	u.Message(s.result())
+}
+
+// Snapshot adds the final standings.
+func (s *GameOverController) Snapshot(u User, snapshot *StateSnapshotMessage) {
+	snapshot.Standings = s.game.Standings()
+}
+
+// Timer is never set during the game over state.
+func (s *GameOverController) Timer(tick time.Duration) {}
+
 // NewStateController creates a state controller based on the requested state.
 func NewStateController(game *Game, state GameState) StateController {
 	switch state {
 	case WaitingState:
 		return NewWaitingController(game)
+	case ProductionState:
+		return NewProductionController(game)
 	case AuctionState:
 		return NewAuctionController(game)
 	case TradeState:
 		return NewTradeController(game)
 	case SummaryState:
 		return NewSummaryController(game)
+	case GameOverState:
+		return NewGameOverController(game)
 	default:
 		panic("Unknown state!")
 	}
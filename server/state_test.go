@@ -4,7 +4,7 @@ import "testing"
 
 func TestAuctionBidding(t *testing.T) {
 	connection := TestConnection{}
-	game := NewGame("g", &connection)
+	game := NewGame("g", &connection, DefaultGameConfig())
 	ctrl := NewAuctionController(game)
 
 	u1 := &TestUser{}
@@ -34,21 +34,29 @@ func TestAuctionBidding(t *testing.T) {
 
 func TestAuctionTimeout(t *testing.T) {
 	connection := TestConnection{}
-	game := NewGame("g", &connection)
+	game := NewGame("g", &connection, DefaultGameConfig())
 	ctrl := NewAuctionController(game)
 	game.state = ctrl
+	ctrl.Begin()
 
 	user := &TestUser{}
 	loser := &TestUser{}
 	ctrl.RecieveMessage(user, NewBidMessage(10))
 	ctrl.RecieveMessage(loser, NewBidMessage(5))
+	card := ctrl.card
 
 	// Wait for the auction to end.
 	game.Tick(2 * AuctionBidTime)
 
-	// Expect the winner to get a winning message.
+	// Expect the winner's bid to be held when they bid, and then to get a
+	// winning message and the card.
+	account := NewAccount()
+	account.Gold -= 10
 	want := &TestUser{}
+	want.Message(NewBalanceMessage(account))
 	want.Message(NewAuctionWonMessage())
+	account.AddCard(card)
+	want.Message(NewBalanceMessage(account))
 
 	if diff := CompareMessageLog(user, want); diff != "" {
 		t.Errorf("AuctionWonMessage: %q, %q, diff: %v",
@@ -58,20 +66,28 @@ func TestAuctionTimeout(t *testing.T) {
 
 func TestTradeMechanism(t *testing.T) {
 	connection := TestConnection{}
-	game := NewGame("g", &connection)
+	game := NewGame("g", &connection, DefaultGameConfig())
 	ctrl := NewTradeController(game)
 	game.state = ctrl
 
 	userA := &TestUser{}
 	userB := &TestUser{}
-	ctrl.RecieveMessage(userA, NewTradeMessage("a gold bar"))
-	ctrl.RecieveMessage(userB, NewTradeMessage("a ham sandwich"))
+	game.Account(userA).Credit(0, map[CommodityType]int{Corn: 3})
+	game.Account(userB).Credit(0, map[CommodityType]int{Tomato: 2})
+	ctrl.RecieveMessage(userA, NewTradeMessage(Materials{Corn: 3}))
+	ctrl.RecieveMessage(userB, NewTradeMessage(Materials{Tomato: 2}))
 
-	// Expect the users to exchange messages.
+	// Expect the users to exchange messages and materials.
+	accountA := NewAccount()
+	accountA.Inventory[Tomato] = 2
 	wantA := &TestUser{}
-	wantA.Message(NewTradeCompletedMessage("a ham sandwich"))
+	wantA.Message(NewTradeCompletedMessage(Materials{Tomato: 2}))
+	wantA.Message(NewBalanceMessage(accountA))
+	accountB := NewAccount()
+	accountB.Inventory[Corn] = 3
 	wantB := &TestUser{}
-	wantB.Message(NewTradeCompletedMessage("a gold bar"))
+	wantB.Message(NewTradeCompletedMessage(Materials{Corn: 3}))
+	wantB.Message(NewBalanceMessage(accountB))
 
 	if diff := CompareMessageLog(userA, wantA); diff != "" {
 		t.Errorf("TradeMessage: %q, %q, diff: %v",
@@ -83,15 +99,17 @@ func TestTradeMechanism(t *testing.T) {
 			userB.messageLog, wantB.messageLog, diff)
 	}
 
-	// Subsequent trade is too slow and fails to complete.
+	// Subsequent trade is too slow and fails to complete, so the first
+	// player is told that their trade failed.
 	userC := &TestUser{}
 	userD := &TestUser{}
-	ctrl.RecieveMessage(userC, NewTradeMessage("nothing"))
+	ctrl.RecieveMessage(userC, NewTradeMessage(Materials{}))
 
 	game.Tick(TradeTimeout * 2)
 
-	ctrl.RecieveMessage(userD, NewTradeMessage("something"))
+	ctrl.RecieveMessage(userD, NewTradeMessage(Materials{}))
 	wantC := &TestUser{}
+	wantC.Message(NewTradeFailedMessage())
 	wantD := &TestUser{}
 
 	if diff := CompareMessageLog(userC, wantC); diff != "" {
@@ -107,18 +125,23 @@ func TestTradeMechanism(t *testing.T) {
 
 	userE := &TestUser{}
 	userF := &TestUser{}
-	ctrl.RecieveMessage(userE, NewTradeMessage("widget"))
+	game.Account(userE).Credit(0, map[CommodityType]int{Purple: 1})
+	ctrl.RecieveMessage(userE, NewTradeMessage(Materials{Purple: 1}))
 
 	// Short delay.
 	game.Tick(TradeTimeout*4 + 5)
 
-	ctrl.RecieveMessage(userF, NewTradeMessage("wodget"))
+	ctrl.RecieveMessage(userF, NewTradeMessage(Materials{}))
 
 	// Expect the users to exchange messages.
 	wantE := &TestUser{}
-	wantE.Message(NewTradeCompletedMessage("wodget"))
+	wantE.Message(NewTradeCompletedMessage(Materials{}))
+	wantE.Message(NewBalanceMessage(NewAccount()))
+	accountF := NewAccount()
+	accountF.Inventory[Purple] = 1
 	wantF := &TestUser{}
-	wantF.Message(NewTradeCompletedMessage("widget"))
+	wantF.Message(NewTradeCompletedMessage(Materials{Purple: 1}))
+	wantF.Message(NewBalanceMessage(accountF))
 
 	if diff := CompareMessageLog(userE, wantE); diff != "" {
 		t.Errorf("TradeMessage: %q, %q, diff: %v",
@@ -129,3 +152,172 @@ func TestTradeMechanism(t *testing.T) {
 			userF.messageLog, wantF.messageLog, diff)
 	}
 }
+
+func TestTradeRequiresMaterials(t *testing.T) {
+	connection := TestConnection{}
+	game := NewGame("g", &connection, DefaultGameConfig())
+	ctrl := NewTradeController(game)
+	game.state = ctrl
+
+	// Neither user owns the corn they are offering, so they are each told
+	// that their trade failed.
+	userA := &TestUser{}
+	userB := &TestUser{}
+	ctrl.RecieveMessage(userA, NewTradeMessage(Materials{Corn: 3}))
+	ctrl.RecieveMessage(userB, NewTradeMessage(Materials{Corn: 1}))
+
+	want := &TestUser{}
+	want.Message(NewTradeFailedMessage())
+	if CompareMessageLog(userA, want) != "" || CompareMessageLog(userB, want) != "" {
+		t.Errorf("Expected no trade, got %q and %q",
+			userA.messageLog, userB.messageLog)
+	}
+	if n := game.Account(userB).Inventory[Corn]; n != 0 {
+		t.Errorf("Expected no corn, got %v", n)
+	}
+}
+
+func TestProduction(t *testing.T) {
+	connection := TestConnection{}
+	game := NewGame("g", &connection, DefaultGameConfig())
+	game.Yield[Corn] = 3.0
+	game.Yield[Purple] = 0
+
+	user := &TestUser{name: "farmer"}
+	game.Account(user).Factories[Corn] = 2
+	game.ChangeState(ProductionState)
+
+	output := map[CommodityType]int{
+		Tomato:    StartingFactories,
+		Blueberry: StartingFactories,
+		Corn:      6,
+		Purple:    0,
+	}
+	account := NewAccount()
+	account.Factories[Corn] = 2
+	account.Credit(0, output)
+
+	want := &TestUser{}
+	want.Message(NewBalanceMessage(account))
+	if diff := CompareMessageLog(user, want); diff != "" {
+		t.Errorf("Production balance: %v", diff)
+	}
+
+	expected := TestConnection{}
+	expected.Broadcast(NewGameStateChangedMessage(ProductionState))
+	expected.Broadcast(NewProductionReportMessage([]PlayerProduction{
+		{Id: user.Id(), Name: "farmer", Output: output},
+	}))
+	expected.Broadcast(NewSetClockMessage(ProductionTimeout))
+	if diff := CompareBroadcastLog(connection, expected); diff != "" {
+		t.Errorf("Production report: %v", diff)
+	}
+
+	// Once the production phase is over, the auction begins.
+	game.Tick(2 * ProductionTimeout)
+	if game.state.Name() != AuctionState {
+		t.Errorf("game.state.Name() = %v, want %v", game.state.Name(), AuctionState)
+	}
+}
+
+func TestSummaryStandings(t *testing.T) {
+	connection := TestConnection{}
+	game := NewGame("g", &connection, DefaultGameConfig())
+
+	rich := &TestUser{name: "rich"}
+	poor := &TestUser{name: "poor"}
+	game.ChangeState(ProductionState)
+	game.Account(rich).Credit(30, nil)
+	game.Account(poor).Debit(5, nil)
+	game.Account(poor).Round.AuctionsWon++
+
+	// The trading stage is followed by the summary.
+	game.ChangeState(TradeState)
+	game.Tick(2 * TradingStageTime)
+	if game.state.Name() != SummaryState {
+		t.Fatalf("game.state.Name() = %v, want %v", game.state.Name(), SummaryState)
+	}
+
+	expected := TestConnection{}
+	expected.Broadcast(NewGameStateChangedMessage(SummaryState))
+	expected.Broadcast(NewStandingsMessage(1, []Standing{
+		{Id: rich.Id(), Name: "rich", Gold: StartingGold + 30, GoldEarned: 30},
+		{Id: poor.Id(), Name: "poor", Gold: StartingGold - 5, GoldEarned: -5, AuctionsWon: 1},
+	}))
+	expected.Broadcast(NewSetClockMessage(SummaryStageTime))
+
+	got := TestConnection{broadcastLog: connection.broadcastLog[len(connection.broadcastLog)-3:]}
+	if diff := CompareBroadcastLog(got, expected); diff != "" {
+		t.Errorf("Standings: %v", diff)
+	}
+
+	// After the summary, the next round begins.
+	game.Tick(2*TradingStageTime + 2*SummaryStageTime)
+	if game.state.Name() != ProductionState {
+		t.Errorf("game.state.Name() = %v, want %v", game.state.Name(), ProductionState)
+	}
+}
+
+func TestAuctionStartingBid(t *testing.T) {
+	connection := TestConnection{}
+	game := NewGame("g", &connection, DefaultGameConfig())
+	ctrl := NewAuctionController(game)
+	game.state = ctrl
+	ctrl.Begin()
+
+	u := &TestUser{}
+	ctrl.RecieveMessage(u, NewBidMessage(ctrl.card.StartingBid-1))
+	if ctrl.winner != nil {
+		t.Errorf("Bid below the starting bid was accepted")
+	}
+
+	ctrl.RecieveMessage(u, NewBidMessage(ctrl.card.StartingBid))
+	if ctrl.winner != u {
+		t.Errorf("Expected ctrl.winner = u, got %v", ctrl.winner)
+	}
+}
+
+func TestAuctionEscrow(t *testing.T) {
+	connection := TestConnection{}
+	game := NewGame("g", &connection, DefaultGameConfig())
+	ctrl := NewAuctionController(game)
+	game.state = ctrl
+	ctrl.Begin()
+
+	u1 := &TestUser{}
+	u2 := &TestUser{}
+
+	// Nobody can bid more than they have.
+	ctrl.RecieveMessage(u1, NewBidMessage(StartingGold+1))
+	want := &TestUser{}
+	want.Message(NewBidRejectedMessage(StartingGold+1, "You can't afford that bid."))
+	if diff := CompareMessageLog(u1, want); diff != "" {
+		t.Errorf("Unaffordable bid: %v", diff)
+	}
+
+	// The leading bid is held in escrow.
+	ctrl.RecieveMessage(u1, NewBidMessage(10))
+	if gold := game.Account(u1).Gold; gold != StartingGold-10 {
+		t.Errorf("u1 has %v gold, want %v", gold, StartingGold-10)
+	}
+
+	// The leader can raise their own bid using the escrowed gold.
+	ctrl.RecieveMessage(u1, NewBidMessage(StartingGold))
+	if ctrl.winner != u1 || game.Account(u1).Gold != 0 {
+		t.Errorf("Raising own bid failed: winner %v, gold %v",
+			ctrl.winner, game.Account(u1).Gold)
+	}
+
+	// Once outbid, the escrow is returned.
+	game.Account(u2).Credit(10, nil)
+	ctrl.RecieveMessage(u2, NewBidMessage(StartingGold+1))
+	if gold := game.Account(u1).Gold; gold != StartingGold {
+		t.Errorf("u1 has %v gold, want %v", gold, StartingGold)
+	}
+
+	// The winner isn't charged twice.
+	game.Tick(2 * AuctionBidTime)
+	if gold := game.Account(u2).Gold; gold != StartingGold+10-(StartingGold+1) {
+		t.Errorf("u2 has %v gold, want %v", gold, 9)
+	}
+}
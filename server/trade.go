@@ -0,0 +1,355 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// TradeOfferStatus describes what has happened to a trade offer.
+type TradeOfferStatus string
+
+const (
+	OfferPending   TradeOfferStatus = "pending"
+	OfferAccepted  TradeOfferStatus = "accepted"
+	OfferRejected  TradeOfferStatus = "rejected"
+	OfferCancelled TradeOfferStatus = "cancelled"
+	OfferFailed    TradeOfferStatus = "failed"
+)
+
+// Goods are an amount of gold and commodities which change hands in a
+// trade.
+type Goods struct {
+	Gold      int       `json:"gold"`
+	Materials Materials `json:"materials"`
+}
+
+// Validate checks that the goods only contain known commodities, and no
+// negative amounts.
+func (g Goods) Validate() error {
+	if g.Gold < 0 {
+		return fmt.Errorf("Negative amount of gold: %v", g.Gold)
+	}
+	for c, n := range g.Materials {
+		if !IsCommodity(c) {
+			return fmt.Errorf("Unknown commodity: %q", c)
+		}
+		if n < 0 {
+			return fmt.Errorf("Negative amount of %v: %v", c, n)
+		}
+	}
+	return nil
+}
+
+// TradeOffer is a proposal from one player to another, to give them some
+// goods in exchange for others.
+type TradeOffer struct {
+	Id     int    `json:"id"`
+	From   string `json:"from"`
+	FromId int    `json:"from_id"`
+	To     string `json:"to"`
+	ToId   int    `json:"to_id"`
+	Give   Goods  `json:"give"`
+	Want   Goods  `json:"want"`
+}
+
+// shake is a player's intent to trade the contents of their basket with
+// whoever else shakes at about the same time.
+type shake struct {
+	user  User
+	goods Materials
+	group string
+	time  time.Duration
+}
+
+// matches returns true if the two shakes can be paired. Players who give a
+// group hint are only paired with players in the same group, or with
+// players who didn't give one.
+func (a shake) matches(b shake) bool {
+	if a.user == b.user {
+		return false
+	}
+	return a.group == "" || b.group == "" || a.group == b.group
+}
+
+type pendingOffer struct {
+	offer TradeOffer
+	from  User
+	to    User
+}
+
+// TradeController manages the state of the game during trading. Players can
+// trade by sending each other explicit offers, or by shaking their phones at
+// the same time, which trades the contents of their baskets.
+type TradeController struct {
+	name        GameState
+	game        *Game
+	shakes      []shake
+	offers      map[int]*pendingOffer
+	nextOfferId int
+}
+
+// NewTradeController creates a TradeController instance.
+func NewTradeController(game *Game) *TradeController {
+	return &TradeController{
+		name:   TradeState,
+		game:   game,
+		offers: make(map[int]*pendingOffer),
+	}
+}
+
+// Name returns the name of the current state.
+func (s *TradeController) Name() GameState { return s.name }
+
+// Begin is called when the state becomes active.
+func (s *TradeController) Begin() {
+	// The trading stage ends after a certain time.
+	s.game.SetTimeout(s.game.Config.TradingStageTime)
+	s.game.connection.Broadcast(NewSetClockMessage(s.game.Config.TradingStageTime))
+}
+
+// Timer is called when the stage is over, so just begin next stage.
+func (s *TradeController) Timer(tick time.Duration) {
+	s.game.ChangeState(SummaryState)
+}
+
+// End is called when the state is no longer active.
+func (s *TradeController) End() {
+	// Offers and shakes don't outlast the trading stage.
+	for _, p := range s.offers {
+		s.resolve(p, OfferCancelled)
+	}
+	for _, sh := range s.shakes {
+		sh.user.Message(NewTradeFailedMessage())
+	}
+	s.shakes = nil
+}
+
+// pendingOffers returns the offers which the user is part of, oldest first.
+func (s *TradeController) pendingOffers(u User) []*pendingOffer {
+	var offers []*pendingOffer
+	// Offer ids start from one, and the newest is nextOfferId.
+	for id := 1; id <= s.nextOfferId; id++ {
+		p, ok := s.offers[id]
+		if ok && (p.from == u || p.to == u) {
+			offers = append(offers, p)
+		}
+	}
+	return offers
+}
+
+// Resync sends a reconnecting player the offers which they are part of.
+func (s *TradeController) Resync(u User) {
+	for _, p := range s.pendingOffers(u) {
+		u.Message(NewTradeOfferUpdatedMessage(p.offer, OfferPending))
+	}
+}
+
+// Snapshot adds the offers which the user is part of.
+func (s *TradeController) Snapshot(u User, snapshot *StateSnapshotMessage) {
+	for _, p := range s.pendingOffers(u) {
+		snapshot.Offers = append(snapshot.Offers, p.offer)
+	}
+}
+
+// Tick is called on every tick, so that shakes which weren't matched in
+// time can be expired.
+func (s *TradeController) Tick(tick time.Duration) {
+	s.expireShakes()
+}
+
+// RecieveMessage is called when a user sends the server a message.
+func (s *TradeController) RecieveMessage(u User, m Message) {
+	switch msg := m.(type) {
+	case TradeMessage:
+		s.expireShakes()
+		s.removeShake(u)
+		next := shake{
+			user:  u,
+			goods: msg.Materials,
+			group: msg.Group,
+			time:  s.game.GetTime(),
+		}
+		// Players can only offer materials that they actually own.
+		if !s.game.Account(u).CanAfford(0, msg.Materials) {
+			s.refuseShake(next)
+			return
+		}
+		s.matchShake(next)
+	case TradeOfferMessage:
+		s.makeOffer(u, msg)
+	case TradeAcceptMessage:
+		if p := s.offerTo(u, msg.OfferId); p != nil {
+			if s.exchange(p.from, p.offer.Give, p.to, p.offer.Want) {
+				s.resolve(p, OfferAccepted)
+				s.game.SendBalance(p.from)
+				s.game.SendBalance(p.to)
+			} else {
+				s.resolve(p, OfferFailed)
+			}
+		}
+	case TradeRejectMessage:
+		if p := s.offerTo(u, msg.OfferId); p != nil {
+			s.resolve(p, OfferRejected)
+		}
+	case TradeCancelMessage:
+		p, ok := s.offers[msg.OfferId]
+		if !ok || p.from != u {
+			u.Message(NewErrorMessage("You haven't made that offer."))
+			return
+		}
+		s.resolve(p, OfferCancelled)
+	case LeaveMessage:
+		for _, p := range s.offers {
+			if p.from == u || p.to == u {
+				s.resolve(p, OfferCancelled)
+			}
+		}
+		s.removeShake(u)
+	}
+}
+
+// makeOffer validates a new offer and sends it to its recipient.
+func (s *TradeController) makeOffer(u User, msg TradeOfferMessage) {
+	to := s.game.UserByName(msg.To)
+	if msg.ToId != 0 {
+		to = s.game.UserById(msg.ToId)
+	}
+	if to == nil || to == u {
+		u.Message(NewErrorMessage("You can't trade with that player."))
+		return
+	}
+	if err := msg.Give.Validate(); err != nil {
+		u.Message(NewErrorMessage(err.Error()))
+		return
+	}
+	if err := msg.Want.Validate(); err != nil {
+		u.Message(NewErrorMessage(err.Error()))
+		return
+	}
+	if !s.game.Account(u).CanAfford(msg.Give.Gold, msg.Give.Materials) {
+		u.Message(NewErrorMessage("You don't have the goods you are offering."))
+		return
+	}
+
+	s.nextOfferId++
+	p := &pendingOffer{
+		offer: TradeOffer{
+			Id:     s.nextOfferId,
+			From:   u.Name(),
+			FromId: u.Id(),
+			To:     to.Name(),
+			ToId:   to.Id(),
+			Give:   msg.Give,
+			Want:   msg.Want,
+		},
+		from: u,
+		to:   to,
+	}
+	s.offers[p.offer.Id] = p
+
+	u.Message(NewTradeOfferUpdatedMessage(p.offer, OfferPending))
+	to.Message(NewTradeOfferUpdatedMessage(p.offer, OfferPending))
+}
+
+// offerTo returns the pending offer with the given id, if it was made to the
+// user. Otherwise, the user is told that the offer doesn't exist.
+func (s *TradeController) offerTo(u User, id int) *pendingOffer {
+	p, ok := s.offers[id]
+	if !ok || p.to != u {
+		u.Message(NewErrorMessage("That offer doesn't exist."))
+		return nil
+	}
+	return p
+}
+
+// resolve removes an offer, and informs both parties of what happened to it.
+func (s *TradeController) resolve(p *pendingOffer, status TradeOfferStatus) {
+	delete(s.offers, p.offer.Id)
+	p.from.Message(NewTradeOfferUpdatedMessage(p.offer, status))
+	p.to.Message(NewTradeOfferUpdatedMessage(p.offer, status))
+}
+
+// exchange swaps goods between two players, provided that they both still
+// have what they are giving. Either both sides of the trade happen, or
+// neither does.
+func (s *TradeController) exchange(a User, aGives Goods, b User, bGives Goods) bool {
+	accountA := s.game.Account(a)
+	accountB := s.game.Account(b)
+	if !accountA.CanAfford(aGives.Gold, aGives.Materials) ||
+		!accountB.CanAfford(bGives.Gold, bGives.Materials) {
+		return false
+	}
+
+	accountA.Debit(aGives.Gold, aGives.Materials)
+	accountB.Debit(bGives.Gold, bGives.Materials)
+	accountA.Credit(bGives.Gold, bGives.Materials)
+	accountB.Credit(aGives.Gold, aGives.Materials)
+	accountA.Round.Trades++
+	accountB.Round.Trades++
+	return true
+}
+
+// matchShake pairs the shake with the earliest pending shake that it
+// matches, or queues it if there isn't one.
+func (s *TradeController) matchShake(next shake) {
+	for i, pending := range s.shakes {
+		if pending.matches(next) {
+			s.shakes = append(s.shakes[:i], s.shakes[i+1:]...)
+			s.settle(pending, next)
+			return
+		}
+	}
+	s.shakes = append(s.shakes, next)
+}
+
+// refuseShake turns down a shake which the player can't afford. Whoever it
+// would have been paired with is told that their trade failed too.
+func (s *TradeController) refuseShake(next shake) {
+	for i, pending := range s.shakes {
+		if pending.matches(next) {
+			s.shakes = append(s.shakes[:i], s.shakes[i+1:]...)
+			pending.user.Message(NewTradeFailedMessage())
+			break
+		}
+	}
+	next.user.Message(NewTradeFailedMessage())
+}
+
+// removeShake removes the user's pending shake, if they have one.
+func (s *TradeController) removeShake(u User) {
+	for i, pending := range s.shakes {
+		if pending.user == u {
+			s.shakes = append(s.shakes[:i], s.shakes[i+1:]...)
+			return
+		}
+	}
+}
+
+// expireShakes removes the shakes which have waited longer than the
+// config's TradeTimeout, and tells those players that their trade failed.
+func (s *TradeController) expireShakes() {
+	var pending []shake
+	for _, sh := range s.shakes {
+		if s.game.GetTime()-sh.time < s.game.Config.TradeTimeout {
+			pending = append(pending, sh)
+		} else {
+			sh.user.Message(NewTradeFailedMessage())
+		}
+	}
+	s.shakes = pending
+}
+
+// settle exchanges the materials in two matched shakes.
+func (s *TradeController) settle(a, b shake) {
+	// The first player may have spent their materials since they shook, so
+	// the exchange checks both sides again.
+	if s.exchange(a.user, Goods{Materials: a.goods}, b.user, Goods{Materials: b.goods}) {
+		a.user.Message(NewTradeCompletedMessage(b.goods))
+		b.user.Message(NewTradeCompletedMessage(a.goods))
+		s.game.SendBalance(a.user)
+		s.game.SendBalance(b.user)
+	} else {
+		a.user.Message(NewTradeFailedMessage())
+		b.user.Message(NewTradeFailedMessage())
+	}
+}
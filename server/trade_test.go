@@ -0,0 +1,250 @@
+package main
+
+import "testing"
+
+func newTestTrade() (*Game, *TradeController, *TestUser, *TestUser) {
+	connection := TestConnection{}
+	game := NewGame("g", &connection, DefaultGameConfig())
+	ctrl := NewTradeController(game)
+	game.state = ctrl
+
+	alice := &TestUser{name: "alice"}
+	bob := &TestUser{name: "bob"}
+	game.Account(alice).Credit(0, map[CommodityType]int{Corn: 4})
+	game.Account(bob).Credit(0, map[CommodityType]int{Tomato: 2})
+	return game, ctrl, alice, bob
+}
+
+func TestTradeOfferAccepted(t *testing.T) {
+	game, ctrl, alice, bob := newTestTrade()
+
+	give := Goods{Materials: map[CommodityType]int{Corn: 3}}
+	want := Goods{Gold: 5, Materials: map[CommodityType]int{Tomato: 2}}
+	ctrl.RecieveMessage(alice, NewTradeOfferMessage("bob", give, want))
+
+	offer := TradeOffer{Id: 1, From: "alice", FromId: alice.Id(), To: "bob", ToId: bob.Id(), Give: give, Want: want}
+	expected := &TestUser{}
+	expected.Message(NewTradeOfferUpdatedMessage(offer, OfferPending))
+	if diff := CompareMessageLog(bob, expected); diff != "" {
+		t.Errorf("Offer: %v", diff)
+	}
+
+	// Only the recipient can accept.
+	alice.messageLog = nil
+	ctrl.RecieveMessage(alice, NewTradeAcceptMessage(1))
+	expected = &TestUser{}
+	expected.Message(NewErrorMessage("That offer doesn't exist."))
+	if diff := CompareMessageLog(alice, expected); diff != "" {
+		t.Errorf("Accept own offer: %v", diff)
+	}
+
+	ctrl.RecieveMessage(bob, NewTradeAcceptMessage(1))
+	a := game.Account(alice)
+	b := game.Account(bob)
+	if a.Inventory[Corn] != 1 || a.Inventory[Tomato] != 2 || a.Gold != StartingGold+5 {
+		t.Errorf("Unexpected account for alice: %+v", a)
+	}
+	if b.Inventory[Corn] != 3 || b.Inventory[Tomato] != 0 || b.Gold != StartingGold-5 {
+		t.Errorf("Unexpected account for bob: %+v", b)
+	}
+
+	// The offer can't be accepted twice.
+	bob.messageLog = nil
+	ctrl.RecieveMessage(bob, NewTradeAcceptMessage(1))
+	expected = &TestUser{}
+	expected.Message(NewErrorMessage("That offer doesn't exist."))
+	if diff := CompareMessageLog(bob, expected); diff != "" {
+		t.Errorf("Accept twice: %v", diff)
+	}
+}
+
+func TestTradeOfferFailsWithoutGoods(t *testing.T) {
+	game, ctrl, alice, bob := newTestTrade()
+
+	// Alice can't offer what she doesn't have.
+	give := Goods{Materials: map[CommodityType]int{Purple: 1}}
+	ctrl.RecieveMessage(alice, NewTradeOfferMessage("bob", give, Goods{}))
+	if len(bob.messageLog) != 0 {
+		t.Errorf("Expected bob not to receive the offer, got %q", bob.messageLog)
+	}
+
+	// If bob doesn't have what alice wants, the trade fails on acceptance
+	// and nothing changes hands.
+	give = Goods{Materials: map[CommodityType]int{Corn: 1}}
+	want := Goods{Gold: StartingGold + 1}
+	ctrl.RecieveMessage(alice, NewTradeOfferMessage("bob", give, want))
+	ctrl.RecieveMessage(bob, NewTradeAcceptMessage(1))
+
+	offer := TradeOffer{Id: 1, From: "alice", FromId: alice.Id(), To: "bob", ToId: bob.Id(), Give: give, Want: want}
+	expected := &TestUser{}
+	expected.Message(NewTradeOfferUpdatedMessage(offer, OfferPending))
+	expected.Message(NewTradeOfferUpdatedMessage(offer, OfferFailed))
+	if diff := CompareMessageLog(bob, expected); diff != "" {
+		t.Errorf("Failed offer: %v", diff)
+	}
+	if game.Account(alice).Inventory[Corn] != 4 {
+		t.Errorf("Failed trade changed alice's account: %+v", game.Account(alice))
+	}
+}
+
+func TestTradeOfferRejectAndCancel(t *testing.T) {
+	_, ctrl, alice, bob := newTestTrade()
+
+	ctrl.RecieveMessage(alice, NewTradeOfferMessage("bob", Goods{}, Goods{}))
+	ctrl.RecieveMessage(alice, NewTradeOfferMessage("bob", Goods{}, Goods{}))
+	ctrl.RecieveMessage(bob, NewTradeRejectMessage(1))
+
+	// Only the sender can cancel.
+	ctrl.RecieveMessage(bob, NewTradeCancelMessage(2))
+	ctrl.RecieveMessage(alice, NewTradeCancelMessage(2))
+
+	first := TradeOffer{Id: 1, From: "alice", FromId: alice.Id(), To: "bob", ToId: bob.Id()}
+	second := TradeOffer{Id: 2, From: "alice", FromId: alice.Id(), To: "bob", ToId: bob.Id()}
+	expected := &TestUser{}
+	expected.Message(NewTradeOfferUpdatedMessage(first, OfferPending))
+	expected.Message(NewTradeOfferUpdatedMessage(second, OfferPending))
+	expected.Message(NewTradeOfferUpdatedMessage(first, OfferRejected))
+	expected.Message(NewErrorMessage("You haven't made that offer."))
+	expected.Message(NewTradeOfferUpdatedMessage(second, OfferCancelled))
+	if diff := CompareMessageLog(bob, expected); diff != "" {
+		t.Errorf("Reject and cancel: %v", diff)
+	}
+	if len(ctrl.offers) != 0 {
+		t.Errorf("Expected no pending offers, got %v", ctrl.offers)
+	}
+}
+
+func TestShakeMatchmaking(t *testing.T) {
+	game, ctrl, alice, bob := newTestTrade()
+	carol := &TestUser{name: "carol"}
+	dave := &TestUser{name: "dave"}
+
+	// Three players shake at once. The first two are paired, and the third
+	// waits for somebody else.
+	ctrl.RecieveMessage(alice, NewTradeMessage(Materials{Corn: 1}))
+	ctrl.RecieveMessage(bob, NewTradeMessage(Materials{Tomato: 1}))
+	ctrl.RecieveMessage(carol, NewTradeMessage(Materials{}))
+
+	if game.Account(alice).Inventory[Tomato] != 1 || game.Account(bob).Inventory[Corn] != 1 {
+		t.Errorf("Expected alice and bob to trade")
+	}
+	if len(carol.messageLog) != 0 {
+		t.Errorf("Expected carol to be waiting, got %q", carol.messageLog)
+	}
+
+	// Dave arrives in time, so carol trades with him.
+	game.Tick(TradeTimeout / 2)
+	ctrl.RecieveMessage(dave, NewTradeMessage(Materials{}))
+	if len(carol.messageLog) == 0 || len(dave.messageLog) == 0 {
+		t.Errorf("Expected carol and dave to trade")
+	}
+	if len(ctrl.shakes) != 0 {
+		t.Errorf("Expected no pending shakes, got %v", ctrl.shakes)
+	}
+
+	// Nobody matches alice in time, so she's told that her trade failed.
+	alice.messageLog = nil
+	ctrl.RecieveMessage(alice, NewTradeMessage(Materials{}))
+	game.Tick(2 * TradeTimeout)
+	want := &TestUser{}
+	want.Message(NewTradeFailedMessage())
+	if diff := CompareMessageLog(alice, want); diff != "" {
+		t.Errorf("Expired shake: %v", diff)
+	}
+}
+
+func TestShakeUnaffordable(t *testing.T) {
+	game, ctrl, alice, bob := newTestTrade()
+
+	// Alice only has four corn, so her shake fails, and so does bob's.
+	ctrl.RecieveMessage(bob, NewTradeMessage(Materials{Tomato: 1}))
+	ctrl.RecieveMessage(alice, NewTradeMessage(Materials{Corn: 5}))
+	want := &TestUser{}
+	want.Message(NewTradeFailedMessage())
+	if diff := CompareMessageLog(alice, want); diff != "" {
+		t.Errorf("Alice: %v", diff)
+	}
+	if diff := CompareMessageLog(bob, want); diff != "" {
+		t.Errorf("Bob: %v", diff)
+	}
+	if len(ctrl.shakes) != 0 {
+		t.Errorf("Expected no pending shakes, got %v", ctrl.shakes)
+	}
+	if game.Account(alice).Inventory[Corn] != 4 || game.Account(bob).Inventory[Tomato] != 2 {
+		t.Errorf("Expected nothing to be traded")
+	}
+}
+
+func TestShakeGroups(t *testing.T) {
+	_, ctrl, alice, bob := newTestTrade()
+	carol := &TestUser{name: "carol"}
+
+	group := func(name string) Message {
+		return TradeMessage{Action: string(TradeAction), Materials: Materials{}, Group: name}
+	}
+
+	// Players in different groups aren't paired.
+	ctrl.RecieveMessage(alice, group("kitchen"))
+	ctrl.RecieveMessage(bob, group("garden"))
+	if len(alice.messageLog) != 0 || len(bob.messageLog) != 0 {
+		t.Errorf("Expected players in different groups not to trade")
+	}
+
+	// The next player in the garden is paired with bob, even though alice
+	// shook first.
+	ctrl.RecieveMessage(carol, group("garden"))
+	if len(bob.messageLog) == 0 || len(carol.messageLog) == 0 {
+		t.Errorf("Expected bob and carol to trade")
+	}
+	if len(alice.messageLog) != 0 {
+		t.Errorf("Expected alice to be waiting, got %q", alice.messageLog)
+	}
+}
+
+func TestTradeOfferById(t *testing.T) {
+	game, ctrl, alice, _ := newTestTrade()
+
+	// Players may share a name, so offers can be addressed by Id.
+	other := &TestUser{name: "bob"}
+	game.Account(other)
+
+	msg := NewTradeOfferMessage("", Goods{}, Goods{}).(TradeOfferMessage)
+	msg.ToId = other.Id()
+	ctrl.RecieveMessage(alice, msg)
+
+	offer := TradeOffer{Id: 1, From: "alice", FromId: alice.Id(), To: "bob", ToId: other.Id()}
+	expected := &TestUser{}
+	expected.Message(NewTradeOfferUpdatedMessage(offer, OfferPending))
+	if diff := CompareMessageLog(other, expected); diff != "" {
+		t.Errorf("Offer by id: %v", diff)
+	}
+}
+
+func TestTradeResync(t *testing.T) {
+	_, ctrl, alice, bob := newTestTrade()
+	carol := &TestUser{name: "carol"}
+
+	ctrl.RecieveMessage(alice, NewTradeOfferMessage("bob", Goods{}, Goods{}))
+	ctrl.RecieveMessage(alice, NewTradeCancelMessage(1))
+	ctrl.RecieveMessage(bob, NewTradeOfferMessage("alice", Goods{}, Goods{}))
+	ctrl.RecieveMessage(alice, NewTradeOfferMessage("bob", Goods{}, Goods{}))
+
+	// Only the offers which are still pending are sent, including the
+	// newest one.
+	bob.messageLog = nil
+	ctrl.Resync(bob)
+	expected := &TestUser{}
+	expected.Message(NewTradeOfferUpdatedMessage(
+		TradeOffer{Id: 2, From: "bob", FromId: bob.Id(), To: "alice", ToId: alice.Id()}, OfferPending))
+	expected.Message(NewTradeOfferUpdatedMessage(
+		TradeOffer{Id: 3, From: "alice", FromId: alice.Id(), To: "bob", ToId: bob.Id()}, OfferPending))
+	if diff := CompareMessageLog(bob, expected); diff != "" {
+		t.Errorf("Resync: %v", diff)
+	}
+
+	carol.messageLog = nil
+	ctrl.Resync(carol)
+	if len(carol.messageLog) != 0 {
+		t.Errorf("Expected no offers for carol, got %v", carol.messageLog)
+	}
+}